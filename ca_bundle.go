@@ -1,51 +1,172 @@
 package curl
 
 import (
+	"bytes"
+	"crypto/x509"
 	_ "embed"
+	"encoding/pem"
 	"fmt"
 	"os"
-	"path/filepath"
+	"runtime"
 	"sync"
+	"unsafe"
 )
 
 //go:embed misc/cacert.pem
 var embeddedCACertData []byte
 
-var (
-	caCertPath string
-	onceCACert sync.Once
-	caCertErr  error
-)
+// CASource selects the certificate authorities a handle trusts for TLS
+// servers and HTTPS proxies. Use EmbeddedCAs, SystemCAs, PEMCAs,
+// CertificateCAs or CertPoolCAs to create one and CURL.SetCASource to apply
+// it.
+type CASource struct {
+	system   bool
+	embedded bool
+	pem      []byte
+	pool     *x509.CertPool
+}
+
+// EmbeddedCAs returns the Mozilla CA bundle compiled into the package. It is
+// passed to libcurl from memory, so no file is written, and all handles
+// share one copy. EasyInit uses it.
+func EmbeddedCAs() CASource {
+	return CASource{embedded: true, pem: embeddedCACertData}
+}
+
+// embeddedCABlob holds the embedded bundle for libcurl to use in place.
+var embeddedCABlob = sync.OnceValue(func() unsafe.Pointer {
+	return staticBlob(embeddedCACertData)
+})
+
+// SystemCAs returns the operating system's trust store: the native store on
+// Windows, otherwise the bundle named by SSL_CERT_FILE or the first of the
+// well-known bundle locations that exists.
+func SystemCAs() CASource {
+	return CASource{system: true}
+}
+
+// PEMCAs returns a source trusting the PEM encoded certificates in pemCerts.
+func PEMCAs(pemCerts []byte) CASource {
+	return CASource{pem: pemCerts}
+}
 
-func getEmbeddedCACertPath() (string, error) {
-	onceCACert.Do(func() {
-		if len(embeddedCACertData) == 0 {
-			caCertErr = fmt.Errorf("embedded CA cert data is empty, ensure cacert.pem is embedded correctly")
-			return
+// CertificateCAs returns a source trusting certs.
+func CertificateCAs(certs ...*x509.Certificate) CASource {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return CASource{pem: buf.Bytes()}
+}
+
+// CertPoolCAs returns a source trusting the certificates of pool. A pool
+// cannot be enumerated, so the peer chain is verified against it in Go
+// during the handshake, see SSLContext.AddCertificates; libcurl still
+// checks the host name. This happens for every TLS connection of the
+// handle, so with SetCASource the pool applies to HTTPS proxies as well,
+// and SetProxyCASource does not take it. It is not available on Windows.
+func CertPoolCAs(pool *x509.CertPool) CASource {
+	return CASource{pool: pool}
+}
+
+// systemCABundles are the bundle locations of common Linux distributions,
+// BSDs and macOS, in the order crypto/x509 probes them.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+	"/usr/local/etc/ssl/cert.pem",
+	"/usr/local/share/certs/ca-root-nss.crt",
+}
+
+func systemCABundle() (string, error) {
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		return f, nil
+	}
+	for _, f := range systemCABundles {
+		if _, err := os.Stat(f); err == nil {
+			return f, nil
 		}
+	}
+	return "", fmt.Errorf("curl: no system CA bundle found")
+}
 
-		cacheDir, err := os.UserCacheDir()
-		if err != nil {
-			caCertErr = fmt.Errorf("failed to get user cache dir for CA cert: %w", err)
-			return
+// SetCASource replaces the trusted certificate authorities for both the
+// server (OPT_CAINFO_BLOB) and HTTPS proxies (OPT_PROXY_CAINFO_BLOB).
+func (curl *CURL) SetCASource(src CASource) error {
+	if src.pool != nil {
+		if runtime.GOOS == "windows" {
+			return fmt.Errorf("curl: CertPool CA sources are not available on windows")
 		}
-		tempDir := filepath.Join(cacheDir, "go-curl-impersonate", "cacerts")
-		if err := os.MkdirAll(tempDir, 0755); err != nil {
-			caCertErr = fmt.Errorf("failed to create temp dir for CA cert '%s': %w", tempDir, err)
-			return
+		// No bundle is loaded, so that only the pool is trusted.
+		for _, opt := range []EasyOpt{OPT_CAINFO_BLOB, OPT_CAINFO, OPT_PROXY_CAINFO_BLOB, OPT_PROXY_CAINFO} {
+			if err := curl.Setopt(opt, nil); err != nil {
+				return err
+			}
 		}
+		curl.caPool = src.pool
+		return curl.installSSLCtxCallback()
+	}
+	curl.caPool = nil
+	if err := curl.applyCASource(src, OPT_CAINFO_BLOB, OPT_CAINFO, OPT_SSL_OPTIONS, curl.sslOptions); err != nil {
+		return err
+	}
+	return curl.SetProxyCASource(src)
+}
 
-		caCertPath = filepath.Join(tempDir, "embedded-cacert.pem")
+// SetProxyCASource replaces the trusted certificate authorities for HTTPS
+// proxies only. It does not take a CertPoolCAs source.
+func (curl *CURL) SetProxyCASource(src CASource) error {
+	if src.pool != nil {
+		return fmt.Errorf("curl: a CertPool CA source applies to all connections, use SetCASource")
+	}
+	return curl.applyCASource(src, OPT_PROXY_CAINFO_BLOB, OPT_PROXY_CAINFO, OPT_PROXY_SSL_OPTIONS, curl.proxySSLOptions)
+}
 
-		err = os.WriteFile(caCertPath, embeddedCACertData, 0644)
+// applyCASource sets src through blobOpt or fileOpt and clears the other,
+// so that only src is trusted. SSLOPT_NATIVE_CA is merged into or removed
+// from sslOptions, keeping the other bits.
+func (curl *CURL) applyCASource(src CASource, blobOpt, fileOpt, sslOptionsOpt EasyOpt, sslOptions int64) error {
+	native := sslOptions &^ SSLOPT_NATIVE_CA
+	if src.system && runtime.GOOS == "windows" {
+		native |= SSLOPT_NATIVE_CA
+	}
+	if native != sslOptions {
+		if err := curl.Setopt(sslOptionsOpt, native); err != nil {
+			return err
+		}
+	}
+
+	if src.system {
+		if err := curl.Setopt(blobOpt, nil); err != nil {
+			return err
+		}
+		if runtime.GOOS == "windows" {
+			return curl.Setopt(fileOpt, nil)
+		}
+		bundle, err := systemCABundle()
 		if err != nil {
-			caCertErr = fmt.Errorf("failed to write embedded CA cert to '%s': %w", caCertPath, err)
-			return
+			return err
 		}
-	})
-	if caCertErr != nil {
-		return "", caCertErr
+		return curl.Setopt(fileOpt, bundle)
 	}
 
-	return caCertPath, nil
+	if len(bytes.TrimSpace(src.pem)) == 0 {
+		return fmt.Errorf("curl: CA source has no certificates")
+	}
+	if err := curl.Setopt(fileOpt, nil); err != nil {
+		return err
+	}
+	if src.embedded {
+		// Every handle uses the bundle, so it is not copied per handle.
+		p := curl.handle
+		if p == nil {
+			return fmt.Errorf("curl: easy handle is nil")
+		}
+		return newCurlError(CurlEasySetoptBlobNoCopy(p, int(blobOpt), embeddedCABlob(), len(embeddedCACertData)))
+	}
+	return curl.Setopt(blobOpt, src.pem)
 }
@@ -0,0 +1,89 @@
+package curl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSetCASource(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+
+	// EasyInit trusts the embedded bundle, which lacks the test certificate.
+	if err := easy.Perform(); !errors.Is(err, CurlError(E_PEER_FAILED_VERIFICATION)) {
+		t.Errorf("error should be %v and is %v.", CurlError(E_PEER_FAILED_VERIFICATION), err)
+	}
+
+	if err := easy.SetCASource(CertificateCAs(ts.Certificate())); err != nil {
+		t.Fatal(err)
+	}
+	easy.Setopt(OPT_FRESH_CONNECT, true)
+	if err := easy.Perform(); err != nil {
+		t.Errorf("transfer trusting the test certificate failed: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	if err := easy.SetCASource(CertPoolCAs(roots)); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); !errors.Is(err, CurlError(E_PEER_FAILED_VERIFICATION)) {
+		t.Errorf("error with an empty pool should be %v and is %v.", CurlError(E_PEER_FAILED_VERIFICATION), err)
+	}
+	roots.AddCert(ts.Certificate())
+	if err := easy.Perform(); err != nil {
+		t.Errorf("transfer trusting the test certificate pool failed: %v", err)
+	}
+	if err := easy.SetProxyCASource(CertPoolCAs(roots)); err == nil {
+		t.Error("CertPool source should be rejected for proxies only.")
+	}
+
+	if err := easy.SetCASource(PEMCAs(nil)); err == nil {
+		t.Error("empty PEM source should be rejected.")
+	}
+}
+
+func TestSetCASourceReplacesSystem(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the system store on windows is not a bundle file")
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	// Make the test certificate the system bundle.
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSL_CERT_FILE", bundle)
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_FRESH_CONNECT, true)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+
+	if err := easy.SetCASource(SystemCAs()); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatalf("transfer trusting the system bundle failed: %v", err)
+	}
+	if err := easy.SetCASource(PEMCAs(embeddedCACertData)); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); !errors.Is(err, CurlError(E_PEER_FAILED_VERIFICATION)) {
+		t.Errorf("PEM source should replace the system bundle, error should be %v and is %v.", CurlError(E_PEER_FAILED_VERIFICATION), err)
+	}
+}
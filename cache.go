@@ -0,0 +1,549 @@
+package curl
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatus tells how the cache handled the last transfer of a handle.
+type CacheStatus int
+
+const (
+	// CacheBypass means the cache did not take part, e.g. for a POST or a
+	// request with Cache-Control: no-store.
+	CacheBypass CacheStatus = iota
+	// CacheMiss means the response came from the network.
+	CacheMiss
+	// CacheHit means a fresh stored response was served without a request.
+	CacheHit
+	// CacheRevalidated means the server confirmed a stale stored response
+	// with 304 Not Modified, and it was served.
+	CacheRevalidated
+)
+
+func (s CacheStatus) String() string {
+	switch s {
+	case CacheMiss:
+		return "miss"
+	case CacheHit:
+		return "hit"
+	case CacheRevalidated:
+		return "revalidated"
+	}
+	return "bypass"
+}
+
+// heuristicStatuses may be cached without explicit freshness information,
+// RFC 9110 section 15.1.
+var heuristicStatuses = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// Cache is an HTTP cache following RFC 9111 in front of attached handles.
+// Fresh responses to GET requests are served through the handle's header
+// and write callbacks without a transfer; stale ones are revalidated with
+// If-None-Match and If-Modified-Since (OPT_TIMECONDITION).
+type Cache struct {
+	Store CacheStore
+	// Shared makes the cache behave like a shared cache: it honours
+	// s-maxage and does not store private responses.
+	Shared bool
+	// MaxEntrySize skips storing bodies larger than this many bytes. Zero
+	// means no limit.
+	MaxEntrySize int
+
+	mu      sync.Mutex
+	handles map[*CURL]*cacheTransfer
+	now     func() time.Time
+}
+
+type cacheMode int
+
+const (
+	cacheModeBypass cacheMode = iota
+	cacheModeMiss
+	cacheModeHit
+	cacheModeRevalidate
+	cacheModeInvalidate
+)
+
+// cacheTransfer is the cache state of one handle.
+type cacheTransfer struct {
+	headerTap, writeTap func([]byte)
+
+	mode        cacheMode
+	key         string
+	entry       *CacheEntry
+	requestTime time.Time
+	conditional bool
+	// lines are all header lines of the transfer, header the final block.
+	lines, header []string
+	body          []byte
+	tooBig        bool
+	status        CacheStatus
+}
+
+// NewCache returns a cache backed by store.
+func NewCache(store CacheStore) *Cache {
+	return &Cache{Store: store}
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// Status returns how the cache handled the last transfer of curl.
+func (c *Cache) Status(curl *CURL) CacheStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := c.handles[curl]; t != nil {
+		return t.status
+	}
+	return CacheBypass
+}
+
+// Attach puts the cache in front of every transfer of curl. The cache reads
+// the response as it passes through the handle's callbacks, which may be
+// set before or after attaching.
+func (c *Cache) Attach(curl *CURL) error {
+	c.mu.Lock()
+	if c.handles == nil {
+		c.handles = make(map[*CURL]*cacheTransfer)
+	}
+	if c.handles[curl] != nil {
+		c.mu.Unlock()
+		return nil
+	}
+	t := &cacheTransfer{}
+	c.handles[curl] = t
+	c.mu.Unlock()
+
+	t.headerTap = func(buf []byte) {
+		if t.mode == cacheModeMiss || t.mode == cacheModeRevalidate {
+			line := string(buf)
+			t.lines = append(t.lines, line)
+			if strings.HasPrefix(line, "HTTP/") {
+				t.header = nil
+			}
+			t.header = append(t.header, line)
+		}
+	}
+	t.writeTap = func(buf []byte) {
+		if t.mode == cacheModeMiss || t.mode == cacheModeRevalidate {
+			if c.MaxEntrySize > 0 && len(t.body)+len(buf) > c.MaxEntrySize {
+				t.tooBig = true
+			}
+			if !t.tooBig || t.mode == cacheModeRevalidate {
+				t.body = append(t.body, buf...)
+			}
+		}
+	}
+	if err := curl.addHeaderTap(&t.headerTap); err != nil {
+		return err
+	}
+	if err := curl.addWriteTap(&t.writeTap); err != nil {
+		return err
+	}
+	curl.addTransferHook(c.hook(t))
+	return nil
+}
+
+// AttachMulti attaches the cache to every handle subsequently added to m.
+func (c *Cache) AttachMulti(m *CURLM) {
+	m.addHooks = append(m.addHooks, c.Attach)
+}
+
+func (c *Cache) hook(t *cacheTransfer) *transferHook {
+	return &transferHook{
+		start: func(curl *CURL) error { return c.start(curl, t) },
+		serve: func(curl *CURL) (bool, error) {
+			if t.mode != cacheModeHit {
+				return false, nil
+			}
+			return true, c.replay(curl, t, t.entry)
+		},
+		done: func(curl *CURL, err error) error { return c.done(curl, t, err) },
+		detach: func(curl *CURL) {
+			c.mu.Lock()
+			if c.handles[curl] == t {
+				delete(c.handles, curl)
+			}
+			c.mu.Unlock()
+		},
+	}
+}
+
+func (c *Cache) start(curl *CURL, t *cacheTransfer) error {
+	now := c.clock()
+	*t = cacheTransfer{headerTap: t.headerTap, writeTap: t.writeTap, key: curl.url, requestTime: now}
+
+	method := curl.requestMethod()
+	reqHeader := parseHeaderLines(curl.httpHeader)
+	reqCC := parseCacheControl(reqHeader)
+	switch {
+	case method != "GET" && method != "HEAD":
+		t.mode = cacheModeInvalidate
+		return nil
+	case method == "HEAD" || reqCC.has("no-store"):
+		return nil
+	}
+
+	t.mode = cacheModeMiss
+	e, err := c.Store.Get(t.key)
+	if err != nil {
+		curl.logAttrs(_WARN, "curl: cache lookup failed", slog.Any("error", err))
+		return nil
+	}
+	if e == nil || !varyMatches(e, reqHeader) {
+		return nil
+	}
+	t.entry = e
+
+	header := e.header()
+	cc := parseCacheControl(header)
+	noCache := reqCC.has("no-cache") || cc.has("no-cache") ||
+		(reqHeader.Get("Cache-Control") == "" && strings.EqualFold(reqHeader.Get("Pragma"), "no-cache"))
+	if !noCache && c.fresh(e, header, cc, reqCC, now) {
+		t.mode = cacheModeHit
+		return nil
+	}
+
+	etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return nil
+	}
+	t.mode = cacheModeRevalidate
+	t.conditional = true
+	// A revalidation may end with the stored response, so hold the live one
+	// back until the transfer is done.
+	curl.holdResponse = true
+	if etag != "" {
+		h := append(append([]string(nil), curl.httpHeader...), "If-None-Match: "+etag)
+		user := curl.httpHeader
+		if err := curl.Setopt(OPT_HTTPHEADER, h); err != nil {
+			return err
+		}
+		// Keep remembering the user's headers, not the conditional ones.
+		curl.httpHeader = user
+	}
+	if lm, err := http.ParseTime(lastModified); err == nil {
+		if err := curl.Setopt(OPT_TIMECONDITION, TIMECOND_IFMODSINCE); err != nil {
+			return err
+		}
+		if err := curl.Setopt(OPT_TIMEVALUE_LARGE, lm.Unix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore undoes the conditional request options.
+func (c *Cache) restore(curl *CURL, t *cacheTransfer) error {
+	if !t.conditional {
+		return nil
+	}
+	t.conditional = false
+	curl.holdResponse = false
+	var h any
+	if curl.httpHeader != nil {
+		h = curl.httpHeader
+	}
+	if err := curl.Setopt(OPT_HTTPHEADER, h); err != nil {
+		return err
+	}
+	return curl.Setopt(OPT_TIMECONDITION, TIMECOND_NONE)
+}
+
+func (c *Cache) done(curl *CURL, t *cacheTransfer, err error) error {
+	if rerr := c.restore(curl, t); rerr != nil && err == nil {
+		err = rerr
+	}
+	switch t.mode {
+	case cacheModeBypass:
+		return err
+	case cacheModeHit:
+		t.status = CacheHit
+		return err
+	case cacheModeInvalidate:
+		if err == nil && curl.getinfoInt(INFO_RESPONSE_CODE) < 400 {
+			if derr := c.Store.Delete(t.key); derr != nil {
+				curl.logAttrs(_WARN, "curl: cache invalidation failed", slog.Any("error", derr))
+			}
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	t.status = CacheMiss
+	now := c.clock()
+	status := int(curl.getinfoInt(INFO_RESPONSE_CODE))
+	if t.mode == cacheModeRevalidate {
+		if status == 304 || curl.getinfoInt(INFO_CONDITION_UNMET) == 1 {
+			e := t.entry.revalidated(t.header, t.requestTime, now)
+			c.store(curl, t.key, e)
+			t.status = CacheRevalidated
+			if err := c.replay(curl, t, e); err != nil {
+				return err
+			}
+			return nil
+		}
+		// The stored response is outdated; pass on the new one.
+		if err := passResponse(curl, t.lines, t.body); err != nil {
+			return err
+		}
+		if c.MaxEntrySize > 0 && len(t.body) > c.MaxEntrySize {
+			t.tooBig = true
+		}
+	}
+
+	if t.tooBig {
+		return nil
+	}
+	e := &CacheEntry{
+		URL:          t.key,
+		Status:       status,
+		Header:       t.header,
+		Body:         t.body,
+		RequestTime:  t.requestTime,
+		ResponseTime: now,
+	}
+	if c.storable(curl, e) {
+		c.store(curl, t.key, e)
+	}
+	return nil
+}
+
+func (c *Cache) store(curl *CURL, key string, e *CacheEntry) {
+	if err := c.Store.Set(key, e); err != nil {
+		curl.logAttrs(_WARN, "curl: cache store failed", slog.Any("error", err))
+	}
+}
+
+// replay serves e through the handle's callbacks and makes Getinfo report it.
+func (c *Cache) replay(curl *CURL, t *cacheTransfer, e *CacheEntry) error {
+	header := e.header()
+	curl.servedInfo = map[Info]any{
+		INFO_RESPONSE_CODE: int64(e.Status),
+		INFO_CONTENT_TYPE:  header.Get("Content-Type"),
+		INFO_SIZE_DOWNLOAD: float64(len(e.Body)),
+	}
+	if t.mode == cacheModeHit {
+		curl.servedInfo[INFO_EFFECTIVE_URL] = e.URL
+		curl.servedInfo[INFO_TOTAL_TIME] = float64(0)
+	}
+	return passResponse(curl, e.Header, e.Body)
+}
+
+// passResponse hands a response to the handle's current header and write
+// callbacks, bypassing the taps that have seen it already or must not
+// record it.
+func passResponse(curl *CURL, header []string, body []byte) error {
+	for _, line := range header {
+		if !curl.passHeader([]byte(line)) {
+			return CurlError(E_WRITE_ERROR)
+		}
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if n, pause := curl.passWrite(body); pause || n != len(body) {
+		return CurlError(E_WRITE_ERROR)
+	}
+	return nil
+}
+
+// storable reports whether e may be stored, RFC 9111 section 3.
+func (c *Cache) storable(curl *CURL, e *CacheEntry) bool {
+	if e.Status < 200 || e.Status == 206 || e.Status == 304 {
+		return false
+	}
+	reqHeader := parseHeaderLines(curl.httpHeader)
+	header := e.header()
+	cc := parseCacheControl(header)
+	if parseCacheControl(reqHeader).has("no-store") || cc.has("no-store") {
+		return false
+	}
+	if c.Shared && cc.has("private") {
+		return false
+	}
+	if c.Shared && reqHeader.Get("Authorization") != "" &&
+		!cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return false
+	}
+	vary := header.Values("Vary")
+	for _, v := range vary {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+	if !(cc.has("public") || cc.has("max-age") || header.Get("Expires") != "" ||
+		(c.Shared && cc.has("s-maxage")) || (!c.Shared && cc.has("private")) ||
+		heuristicStatuses[e.Status]) {
+		return false
+	}
+	for _, v := range vary {
+		for _, name := range strings.Split(v, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				if e.Vary == nil {
+					e.Vary = make(map[string]string)
+				}
+				e.Vary[name] = reqHeader.Get(name)
+			}
+		}
+	}
+	return true
+}
+
+// fresh reports whether e may be served without revalidation, RFC 9111
+// section 4.2, taking the request's max-age, min-fresh and max-stale into
+// account.
+func (c *Cache) fresh(e *CacheEntry, header http.Header, cc, reqCC cacheControl, now time.Time) bool {
+	lifetime := c.freshnessLifetime(e, header, cc)
+	age := currentAge(e, header, now)
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok {
+		lifetime -= minFresh
+	}
+	if age < lifetime {
+		return true
+	}
+	if cc.has("must-revalidate") || (c.Shared && cc.has("proxy-revalidate")) {
+		return false
+	}
+	if reqCC.has("max-stale") {
+		maxStale, ok := reqCC.seconds("max-stale")
+		return !ok || age-lifetime <= maxStale
+	}
+	return false
+}
+
+func (c *Cache) freshnessLifetime(e *CacheEntry, header http.Header, cc cacheControl) time.Duration {
+	if c.Shared {
+		if d, ok := cc.seconds("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = e.ResponseTime
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+	if lm, err := http.ParseTime(header.Get("Last-Modified")); err == nil &&
+		(heuristicStatuses[e.Status] || cc.has("public")) {
+		return date.Sub(lm) / 10
+	}
+	return 0
+}
+
+// currentAge is the age calculation of RFC 9111 section 4.2.3.
+func currentAge(e *CacheEntry, header http.Header, now time.Time) time.Duration {
+	var apparentAge time.Duration
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		apparentAge = max(0, e.ResponseTime.Sub(date))
+	}
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(strings.TrimSpace(header.Get("Age")), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(e.ResponseTime)
+}
+
+func varyMatches(e *CacheEntry, reqHeader http.Header) bool {
+	for name, value := range e.Vary {
+		if reqHeader.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *CacheEntry) header() http.Header {
+	return parseHeaderLines(e.Header)
+}
+
+// revalidated returns a copy of e updated with the header fields of a 304
+// response, RFC 9111 section 4.3.4.
+func (e *CacheEntry) revalidated(lines []string, requestTime, responseTime time.Time) *CacheEntry {
+	updated := parseHeaderLines(lines)
+	for _, name := range []string{"Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range"} {
+		updated.Del(name)
+	}
+	n := *e
+	n.Header = nil
+	for _, line := range e.Header {
+		name, _, ok := strings.Cut(line, ":")
+		if ok && !strings.HasPrefix(line, "HTTP/") && updated.Get(strings.TrimSpace(name)) != "" {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			for _, l := range lines {
+				if name, _, ok := strings.Cut(l, ":"); ok && !strings.HasPrefix(l, "HTTP/") && updated.Get(strings.TrimSpace(name)) != "" {
+					n.Header = append(n.Header, l)
+				}
+			}
+		}
+		n.Header = append(n.Header, line)
+	}
+	n.RequestTime, n.ResponseTime = requestTime, responseTime
+	return &n
+}
+
+// parseHeaderLines parses "Name: value" lines, skipping status lines.
+func parseHeaderLines(lines []string) http.Header {
+	return ParseHeader(lines).HTTPHeader()
+}
+
+// cacheControl holds the directives of Cache-Control header fields.
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
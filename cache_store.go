@@ -0,0 +1,161 @@
+package curl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a stored response.
+type CacheEntry struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	// Header are the header lines of the final response as passed to the
+	// header callback, from the status line to the terminating blank line.
+	Header []string `json:"header"`
+	Body   []byte   `json:"body,omitempty"`
+	// RequestTime and ResponseTime bracket the transfer that produced or
+	// last revalidated the entry, for the age calculation of RFC 9111.
+	RequestTime  time.Time `json:"requestTime"`
+	ResponseTime time.Time `json:"responseTime"`
+	// Vary holds the request header values named by the Vary header.
+	Vary map[string]string `json:"vary,omitempty"`
+}
+
+// CacheStore stores cache entries by key. Get returns nil without error
+// for a missing entry. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, error)
+	Set(key string, e *CacheEntry) error
+	Delete(key string) error
+}
+
+// MemoryCacheStore keeps entries in memory. The zero value is an empty
+// store without a limit.
+type MemoryCacheStore struct {
+	// MaxEntries evicts the oldest entries beyond this number. Zero means
+	// no limit.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+	order   []string
+}
+
+// NewMemoryCacheStore returns an in-memory store of at most maxEntries
+// entries, or unlimited for zero.
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{MaxEntries: maxEntries}
+}
+
+func (s *MemoryCacheStore) Get(key string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+func (s *MemoryCacheStore) Set(key string, e *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]*CacheEntry)
+	}
+	if _, ok := s.entries[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.entries[key] = e
+	for s.MaxEntries > 0 && len(s.order) > s.MaxEntries {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+	return nil
+}
+
+func (s *MemoryCacheStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok {
+		return nil
+	}
+	delete(s.entries, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// DiskCacheStore keeps every entry as a JSON file in a directory.
+type DiskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore returns a store in dir, creating it if needed.
+func NewDiskCacheStore(dir string) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("curl: failed to create cache directory: %w", err)
+	}
+	return &DiskCacheStore{dir: dir}, nil
+}
+
+func (s *DiskCacheStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *DiskCacheStore) Get(key string) (*CacheEntry, error) {
+	b, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("curl: failed to read cache entry: %w", err)
+	}
+	e := &CacheEntry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("curl: failed to parse cache entry: %w", err)
+	}
+	return e, nil
+}
+
+// Set writes the entry to a temporary file first, so that readers never
+// see a partial entry.
+func (s *DiskCacheStore) Set(key string, e *CacheEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("curl: failed to write cache entry: %w", err)
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("curl: failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+func (s *DiskCacheStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("curl: failed to delete cache entry: %w", err)
+	}
+	return nil
+}
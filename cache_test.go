@@ -0,0 +1,172 @@
+package curl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func cacheServer(requests *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fresh", func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("fresh"))
+	})
+	mux.HandleFunc("/etag", func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("tagged"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCache(t *testing.T) {
+	var requests int
+	ts := cacheServer(&requests)
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	var body string
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		body += string(buf)
+		return true
+	})
+	cache := NewCache(NewMemoryCacheStore(0))
+	if err := cache.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path     string
+		status   CacheStatus
+		requests int
+		body     string
+	}{
+		{"/fresh", CacheMiss, 1, "fresh"},
+		{"/fresh", CacheHit, 1, "fresh"},
+		{"/etag", CacheMiss, 2, "tagged"},
+		{"/etag", CacheRevalidated, 3, "tagged"},
+	} {
+		body = ""
+		easy.Setopt(OPT_URL, ts.URL+tc.path)
+		if err := easy.Perform(); err != nil {
+			t.Fatal(err)
+		}
+		if s := cache.Status(easy); s != tc.status {
+			t.Errorf("%s: cache status should be %v and is %v.", tc.path, tc.status, s)
+		}
+		if requests != tc.requests {
+			t.Errorf("%s: server should see %d requests and saw %d.", tc.path, tc.requests, requests)
+		}
+		if body != tc.body {
+			t.Errorf("%s: body should be %q and is %q.", tc.path, tc.body, body)
+		}
+		if code, _ := easy.Getinfo(INFO_RESPONSE_CODE); code != int64(200) {
+			t.Errorf("%s: response code should be 200 and is %v.", tc.path, code)
+		}
+	}
+}
+
+func TestCacheWithDo(t *testing.T) {
+	var requests int
+	ts := cacheServer(&requests)
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	cache := NewCache(NewMemoryCacheStore(0))
+	if err := cache.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+
+	// Do sets its own callbacks on every call, after the cache is attached.
+	for i, want := range []CacheStatus{CacheMiss, CacheHit} {
+		resp, err := easy.Do(NewRequest("GET", ts.URL+"/fresh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := cache.Status(easy); s != want {
+			t.Errorf("call %d: cache status should be %v and is %v.", i, want, s)
+		}
+		if resp.Text() != "fresh" || resp.StatusCode != 200 {
+			t.Errorf("call %d: response should be 200 %q, got %d %q.", i, "fresh", resp.StatusCode, resp.Text())
+		}
+	}
+	if requests != 1 {
+		t.Errorf("server should see 1 request and saw %d.", requests)
+	}
+}
+
+func TestCacheFreshness(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := &CacheEntry{
+		Status: 200,
+		Header: []string{
+			"HTTP/1.1 200 OK\r\n",
+			"Date: " + now.Format(http.TimeFormat) + "\r\n",
+			"Cache-Control: max-age=10, s-maxage=100\r\n",
+			"\r\n",
+		},
+		RequestTime:  now,
+		ResponseTime: now,
+	}
+	header := e.header()
+	cc := parseCacheControl(header)
+	private, shared := &Cache{}, &Cache{Shared: true}
+	later := now.Add(50 * time.Second)
+
+	if private.fresh(e, header, cc, cacheControl{}, later) {
+		t.Error("private cache should use max-age and find the entry stale.")
+	}
+	if !shared.fresh(e, header, cc, cacheControl{}, later) {
+		t.Error("shared cache should use s-maxage and find the entry fresh.")
+	}
+	if !private.fresh(e, header, cc, cacheControl{"max-stale": ""}, later) {
+		t.Error("max-stale without value should accept a stale entry.")
+	}
+}
+
+func TestMemoryCacheStoreZeroValue(t *testing.T) {
+	s := &MemoryCacheStore{MaxEntries: 1}
+	for _, key := range []string{"a", "b"} {
+		if err := s.Set(key, &CacheEntry{URL: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e, _ := s.Get("a"); e != nil {
+		t.Error("oldest entry should be evicted beyond MaxEntries.")
+	}
+	if e, _ := s.Get("b"); e == nil || e.URL != "b" {
+		t.Errorf("newest entry should be kept, got %+v.", e)
+	}
+}
+
+func TestDiskCacheStore(t *testing.T) {
+	s, err := NewDiskCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := s.Get("k"); e != nil || err != nil {
+		t.Errorf("missing entry should be nil without error, got %v %v.", e, err)
+	}
+	if err := s.Set("k", &CacheEntry{URL: "u", Body: []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := s.Get("k"); err != nil || e == nil || string(e.Body) != "b" {
+		t.Errorf("stored entry should round-trip, got %+v %v.", e, err)
+	}
+	if err := s.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := s.Get("k"); e != nil {
+		t.Error("deleted entry should be gone.")
+	}
+}
@@ -12,35 +12,35 @@ import (
 
 //export goCallHeaderFunction
 func goCallHeaderFunction(ptr *C.char, size C.size_t, ctx unsafe.Pointer) C.size_t {
-	curl := context_map.Get(uintptr(ctx))
-	if curl == nil || curl.headerFunction == nil {
+	curl := curlFromUserdata(uintptr(ctx))
+	if curl == nil {
 		return 0
 	}
-	buf := C.GoBytes(unsafe.Pointer(ptr), C.int(size*1))
-
-	if (*curl.headerFunction)(buf, curl.headerData) {
-		return C.size_t(size * 1)
+	// Like the trampolines, alias libcurl's buffer instead of copying it.
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(ptr)), int(size))
+	if curl.onHeader(buf) {
+		return size
 	}
 	return GetCurlWritefuncPause()
 }
 
 //export goCallWriteFunction
 func goCallWriteFunction(ptr *C.char, size C.size_t, ctx unsafe.Pointer) C.size_t {
-	curl := context_map.Get(uintptr(ctx))
-	if curl == nil || curl.writeFunction == nil {
+	curl := curlFromUserdata(uintptr(ctx))
+	if curl == nil {
 		return 0
 	}
-	buf := C.GoBytes(unsafe.Pointer(ptr), C.int(size*1))
-
-	if (*curl.writeFunction)(buf, curl.writeData) {
-		return C.size_t(size * 1) // Return total bytes processed
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(ptr)), int(size))
+	n, pause := curl.onWrite(buf)
+	if pause {
+		return GetCurlWritefuncPause()
 	}
-	return GetCurlWritefuncPause()
+	return C.size_t(n)
 }
 
 //export goCallProgressFunction
 func goCallProgressFunction(dltotalC C.double, dlnowC C.double, ultotalC C.double, ulnowC C.double, ctx unsafe.Pointer) C.int {
-	curl := context_map.Get(uintptr(ctx))
+	curl := curlFromUserdata(uintptr(ctx))
 	if curl == nil || curl.progressFunction == nil {
 		return 0
 	}
@@ -54,7 +54,7 @@ func goCallProgressFunction(dltotalC C.double, dlnowC C.double, ultotalC C.doubl
 
 //export goCallReadFunction
 func goCallReadFunction(ptr *C.char, size C.size_t, numItems C.size_t, ctx unsafe.Pointer) C.size_t {
-	curl := context_map.Get(uintptr(ctx))
+	curl := curlFromUserdata(uintptr(ctx))
 	if curl == nil || curl.readFunction == nil {
 		return GetCurlReadfuncAbort()
 	}
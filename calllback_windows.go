@@ -19,7 +19,7 @@ func GoXferInfoCallback(clientp unsafe.Pointer, dltotal C.double, dlnow C.double
 	gultotal := float64(ultotal)
 	gulnow := float64(ulnow)
 
-	curlHandle := context_map.Get(uintptr(clientp))
+	curlHandle := curlFromUserdata(uintptr(clientp))
 
 	if curlHandle == nil {
 		return 0
@@ -0,0 +1,272 @@
+package curl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CassetteMode selects whether a Cassette records real transfers or replays
+// previously recorded ones.
+type CassetteMode int
+
+const (
+	// CassetteRecord performs transfers with libcurl and stores them.
+	CassetteRecord CassetteMode = iota
+	// CassetteReplay serves stored transfers without touching the network.
+	CassetteReplay
+)
+
+// Fixture is one recorded request/response pair.
+type Fixture struct {
+	Method         string   `json:"method"`
+	URL            string   `json:"url"`
+	RequestHeaders []string `json:"requestHeaders"`
+	RequestBody    []byte   `json:"requestBody,omitempty"`
+
+	Status       int    `json:"status"`
+	EffectiveURL string `json:"effectiveUrl"`
+	ContentType  string `json:"contentType,omitempty"`
+	PrimaryIP    string `json:"primaryIp,omitempty"`
+	// ResponseHeaders are the header lines exactly as passed to the header
+	// callback, including status lines and the terminating blank line.
+	ResponseHeaders []string `json:"responseHeaders"`
+	// ResponseBody is the data passed to the write callback.
+	ResponseBody []byte `json:"responseBody,omitempty"`
+
+	Timings FixtureTimings `json:"timings"`
+}
+
+// FixtureTimings holds the Getinfo *_TIME values of a transfer, in seconds.
+type FixtureTimings struct {
+	NameLookup    float64 `json:"namelookup"`
+	Connect       float64 `json:"connect"`
+	AppConnect    float64 `json:"appconnect"`
+	PreTransfer   float64 `json:"pretransfer"`
+	StartTransfer float64 `json:"starttransfer"`
+	Total         float64 `json:"total"`
+}
+
+// Cassette records transfers to a fixture file, or replays them through the
+// handle's header, write and progress callbacks without invoking libcurl.
+// Getinfo on a replayed handle answers from the fixture for the status,
+// URL, content type, IP, size and timing infos.
+type Cassette struct {
+	path string
+	mode CassetteMode
+
+	mu       sync.Mutex
+	fixtures []*Fixture
+	used     []bool
+	attached map[*CURL]bool
+}
+
+// replayChunkSize matches CURL_MAX_WRITE_SIZE, the largest chunk libcurl
+// passes to a write callback.
+const replayChunkSize = 16 * 1024
+
+// NewCassette returns a cassette backed by the fixture file at path. In
+// replay mode the file is read immediately; in record mode it is written by
+// Save.
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{path: path, mode: mode, attached: make(map[*CURL]bool)}
+	if mode == CassetteReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("curl: failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &c.fixtures); err != nil {
+			return nil, fmt.Errorf("curl: failed to parse cassette '%s': %w", path, err)
+		}
+		c.used = make([]bool, len(c.fixtures))
+	}
+	return c, nil
+}
+
+// Fixtures returns the fixtures recorded or loaded so far.
+func (c *Cassette) Fixtures() []*Fixture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Fixture(nil), c.fixtures...)
+}
+
+// Save writes the recorded fixtures to the cassette file.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	b, err := json.MarshalIndent(c.fixtures, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, b, 0644)
+}
+
+// Attach records or replays every transfer of curl. In record mode the
+// cassette reads the response as it passes through the handle's callbacks,
+// which may be set before or after attaching.
+func (c *Cassette) Attach(curl *CURL) error {
+	c.mu.Lock()
+	c.attached[curl] = true
+	c.mu.Unlock()
+
+	if c.mode == CassetteReplay {
+		curl.addTransferHook(&transferHook{serve: c.serve})
+		return nil
+	}
+	return c.attachRecorder(curl)
+}
+
+// AttachMulti attaches the cassette to every handle subsequently added to m.
+func (c *Cassette) AttachMulti(m *CURLM) {
+	m.addHooks = append(m.addHooks, func(easy *CURL) error {
+		c.mu.Lock()
+		ok := c.attached[easy]
+		c.mu.Unlock()
+		if ok {
+			return nil
+		}
+		return c.Attach(easy)
+	})
+}
+
+func (c *Cassette) attachRecorder(curl *CURL) error {
+	var (
+		fx  *Fixture
+		req harTransfer
+	)
+
+	headerTap := func(buf []byte) {
+		fx.ResponseHeaders = append(fx.ResponseHeaders, string(buf))
+	}
+	if err := curl.addHeaderTap(&headerTap); err != nil {
+		return err
+	}
+	writeTap := func(buf []byte) {
+		fx.ResponseBody = append(fx.ResponseBody, buf...)
+	}
+	if err := curl.addWriteTap(&writeTap); err != nil {
+		return err
+	}
+
+	prevDebug := curl.debugFunction
+	err := curl.Setopt(OPT_DEBUGFUNCTION, func(infoType Info, data []byte, userdata any) {
+		if infoType == INFO_HEADER_OUT || infoType == INFO_DATA_OUT {
+			req.capture(infoType, data, true)
+		}
+		if prevDebug != nil {
+			(*prevDebug)(infoType, data, userdata)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	curl.addTransferHook(&transferHook{
+		start: func(curl *CURL) error {
+			fx = &Fixture{Method: curl.requestMethod(), URL: curl.url}
+			req.reset()
+			return nil
+		},
+		done: func(curl *CURL, err error) error {
+			if err != nil {
+				return err
+			}
+			for _, h := range req.reqHeaders {
+				fx.RequestHeaders = append(fx.RequestHeaders, h.Name+": "+h.Value)
+			}
+			if req.reqBody.Len() > 0 {
+				fx.RequestBody = append([]byte(nil), req.reqBody.Bytes()...)
+			}
+			fx.Status = int(curl.getinfoInt(INFO_RESPONSE_CODE))
+			fx.EffectiveURL = curl.getinfoString(INFO_EFFECTIVE_URL)
+			fx.ContentType = curl.getinfoString(INFO_CONTENT_TYPE)
+			fx.PrimaryIP = curl.getinfoString(INFO_PRIMARY_IP)
+			fx.Timings = FixtureTimings{
+				NameLookup:    curl.getinfoFloat(INFO_NAMELOOKUP_TIME),
+				Connect:       curl.getinfoFloat(INFO_CONNECT_TIME),
+				AppConnect:    curl.getinfoFloat(INFO_APPCONNECT_TIME),
+				PreTransfer:   curl.getinfoFloat(INFO_PRETRANSFER_TIME),
+				StartTransfer: curl.getinfoFloat(INFO_STARTTRANSFER_TIME),
+				Total:         curl.getinfoFloat(INFO_TOTAL_TIME),
+			}
+			c.mu.Lock()
+			c.fixtures = append(c.fixtures, fx)
+			c.mu.Unlock()
+			return nil
+		},
+	})
+	return nil
+}
+
+// next returns the first unused fixture for method and url.
+func (c *Cassette) next(method, url string) *Fixture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, fx := range c.fixtures {
+		if !c.used[i] && strings.EqualFold(fx.Method, method) && fx.URL == url {
+			c.used[i] = true
+			return fx
+		}
+	}
+	return nil
+}
+
+func (c *Cassette) serve(curl *CURL) (bool, error) {
+	method := curl.requestMethod()
+	fx := c.next(method, curl.url)
+	if fx == nil {
+		return true, fmt.Errorf("curl: cassette '%s' has no fixture left for %s %s", c.path, method, curl.url)
+	}
+
+	curl.servedInfo = map[Info]any{
+		INFO_RESPONSE_CODE:      int64(fx.Status),
+		INFO_EFFECTIVE_URL:      fx.EffectiveURL,
+		INFO_EFFECTIVE_METHOD:   fx.Method,
+		INFO_CONTENT_TYPE:       fx.ContentType,
+		INFO_PRIMARY_IP:         fx.PrimaryIP,
+		INFO_SIZE_DOWNLOAD:      float64(len(fx.ResponseBody)),
+		INFO_NAMELOOKUP_TIME:    fx.Timings.NameLookup,
+		INFO_CONNECT_TIME:       fx.Timings.Connect,
+		INFO_APPCONNECT_TIME:    fx.Timings.AppConnect,
+		INFO_PRETRANSFER_TIME:   fx.Timings.PreTransfer,
+		INFO_STARTTRANSFER_TIME: fx.Timings.StartTransfer,
+		INFO_TOTAL_TIME:         fx.Timings.Total,
+	}
+
+	if err := replayResponse(curl, fx.ResponseHeaders, fx.ResponseBody); err != nil {
+		return true, err
+	}
+
+	if curl.progressFunction != nil {
+		size := float64(len(fx.ResponseBody))
+		if !(*curl.progressFunction)(size, size, 0, 0, curl.progressData) {
+			return true, CurlError(E_ABORTED_BY_CALLBACK)
+		}
+	}
+	return true, nil
+}
+
+// replayResponse feeds a stored response to the handle the way libcurl
+// delivers one, through its taps and callbacks.
+func replayResponse(curl *CURL, header []string, body []byte) error {
+	for _, line := range header {
+		if !curl.onHeader([]byte(line)) {
+			return CurlError(E_WRITE_ERROR)
+		}
+	}
+	return writeChunks(curl.onWrite, body)
+}
+
+// writeChunks passes body to write in chunks the size libcurl would use.
+func writeChunks(write func([]byte) (int, bool), body []byte) error {
+	for len(body) > 0 {
+		chunk := body[:min(len(body), replayChunkSize)]
+		if n, pause := write(chunk); pause || n != len(chunk) {
+			return CurlError(E_WRITE_ERROR)
+		}
+		body = body[len(chunk):]
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	serverContent := "A random string"
+	ts := setupTestServer(serverContent)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	url := ts.URL + "/fixture"
+
+	rec, err := NewCassette(path, CassetteRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	easy := EasyInit()
+	easy.Setopt(OPT_URL, url)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	if err := rec.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	easy.Cleanup()
+	ts.Close()
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewCassette(path, CassetteReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	easy = EasyInit()
+	defer easy.Cleanup()
+	var body, headers []byte
+	easy.Setopt(OPT_URL, url)
+	easy.Setopt(OPT_HEADERFUNCTION, func(buf []byte, _ any) bool {
+		headers = append(headers, buf...)
+		return true
+	})
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		body = append(body, buf...)
+		return true
+	})
+	if err := replay.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+
+	mh := MultiInit()
+	defer mh.Cleanup()
+	if err := mh.AddHandle(easy); err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := mh.Info_read()
+	if msg == nil || msg.Err != nil || msg.Easy_handle != easy {
+		t.Fatalf("unexpected replay message: %+v", msg)
+	}
+	mh.RemoveHandle(easy)
+
+	if string(body) != serverContent+"\n" {
+		t.Errorf("replayed body should be %q and is %q.", serverContent+"\n", body)
+	}
+	if len(headers) == 0 {
+		t.Error("replay should pass headers to the header callback.")
+	}
+	if code, _ := easy.Getinfo(INFO_RESPONSE_CODE); code != int64(200) {
+		t.Errorf("replayed response code should be 200 and is %v.", code)
+	}
+	if err := easy.Perform(); err == nil {
+		t.Error("replay should fail once the cassette is exhausted.")
+	}
+}
+
+func TestCassetteReplayToWriteData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	fixtures := []*Fixture{{
+		Method:          "GET",
+		URL:             "http://example.invalid/",
+		Status:          200,
+		ResponseHeaders: []string{"HTTP/1.1 200 OK\r\n", "\r\n"},
+		ResponseBody:    []byte("replayed"),
+	}}
+	b, err := json.Marshal(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := NewCassette(path, CassetteReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, "http://example.invalid/")
+	var body bytes.Buffer
+	easy.Setopt(OPT_WRITEDATA, &body)
+	var status string
+	tap := func(line []byte) {
+		if status == "" {
+			status = string(line)
+		}
+	}
+	easy.addHeaderTap(&tap)
+	if err := replay.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if body.String() != "replayed" {
+		t.Errorf("replayed body should reach WRITEDATA, got %q.", body.String())
+	}
+	if status != "HTTP/1.1 200 OK\r\n" {
+		t.Errorf("header taps should see the replayed status line, got %q.", status)
+	}
+}
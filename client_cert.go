@@ -0,0 +1,84 @@
+package curl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// ClientCertificate is a TLS client certificate for mutual TLS, passed to
+// libcurl from memory through the *_BLOB options.
+type ClientCertificate struct {
+	// CertPEM holds the leaf certificate followed by any intermediates.
+	CertPEM []byte
+	// KeyPEM holds the private key, optionally encrypted with KeyPassword.
+	KeyPEM      []byte
+	KeyPassword string
+}
+
+// ClientCertificateFromTLS converts cert, e.g. from tls.X509KeyPair, to a
+// ClientCertificate. The private key must be an RSA, ECDSA or Ed25519 key
+// held in memory; opaque crypto.Signer implementations cannot be exported.
+func ClientCertificateFromTLS(cert tls.Certificate) (ClientCertificate, error) {
+	if len(cert.Certificate) == 0 {
+		return ClientCertificate{}, fmt.Errorf("curl: client certificate has no certificates")
+	}
+	var certPEM bytes.Buffer
+	for _, der := range cert.Certificate {
+		pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return ClientCertificate{}, fmt.Errorf("curl: failed to encode client certificate key: %w", err)
+	}
+	return ClientCertificate{
+		CertPEM: certPEM.Bytes(),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}),
+	}, nil
+}
+
+// SetClientCertificate presents cert to TLS servers.
+func (curl *CURL) SetClientCertificate(cert ClientCertificate) error {
+	return curl.setClientCertificate(cert, OPT_SSLCERT_BLOB, OPT_SSLCERTTYPE, OPT_SSLKEY_BLOB, OPT_SSLKEYTYPE, OPT_KEYPASSWD)
+}
+
+// SetProxyClientCertificate presents cert to HTTPS proxies.
+func (curl *CURL) SetProxyClientCertificate(cert ClientCertificate) error {
+	return curl.setClientCertificate(cert, OPT_PROXY_SSLCERT_BLOB, OPT_PROXY_SSLCERTTYPE, OPT_PROXY_SSLKEY_BLOB, OPT_PROXY_SSLKEYTYPE, OPT_PROXY_KEYPASSWD)
+}
+
+func (curl *CURL) setClientCertificate(cert ClientCertificate, certOpt, certTypeOpt, keyOpt, keyTypeOpt, passwdOpt EasyOpt) error {
+	if len(cert.CertPEM) == 0 || len(cert.KeyPEM) == 0 {
+		return fmt.Errorf("curl: client certificate and key are required")
+	}
+	if err := curl.Setopt(certOpt, cert.CertPEM); err != nil {
+		return err
+	}
+	if err := curl.Setopt(certTypeOpt, "PEM"); err != nil {
+		return err
+	}
+	if err := curl.Setopt(keyOpt, cert.KeyPEM); err != nil {
+		return err
+	}
+	if err := curl.Setopt(keyTypeOpt, "PEM"); err != nil {
+		return err
+	}
+	if cert.KeyPassword != "" {
+		return curl.Setopt(passwdOpt, cert.KeyPassword)
+	}
+	return curl.Setopt(passwdOpt, nil)
+}
+
+// WithClientCertificate presents cert to TLS servers, see
+// CURL.SetClientCertificate.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *CURL) error {
+		cc, err := ClientCertificateFromTLS(cert)
+		if err != nil {
+			return err
+		}
+		return c.SetClientCertificate(cc)
+	}
+}
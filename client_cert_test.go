@@ -0,0 +1,60 @@
+package curl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-curl client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotCN string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			gotCN = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	easy, err := NewEasy(
+		WithCASource(CertificateCAs(ts.Certificate())),
+		WithClientCertificate(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}),
+		WithOption(OPT_URL, ts.URL),
+		WithOption(OPT_WRITEFUNCTION, func([]byte, any) bool { return true }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer easy.Cleanup()
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if gotCN != "go-curl client" {
+		t.Errorf("server should see client certificate %q and saw %q.", "go-curl client", gotCN)
+	}
+}
@@ -103,6 +103,13 @@ const (
 	READFUNC_PAUSE = C.CURL_READFUNC_PAUSE
 )
 
+// for OPT_SEEKFUNCTION, return a int flag
+const (
+	SEEKFUNC_OK       = C.CURL_SEEKFUNC_OK
+	SEEKFUNC_FAIL     = C.CURL_SEEKFUNC_FAIL
+	SEEKFUNC_CANTSEEK = C.CURL_SEEKFUNC_CANTSEEK
+)
+
 // for easy.Setopt(OPT_HTTP_VERSION, flag)
 const (
 	HTTP_VERSION_NONE = C.CURL_HTTP_VERSION_NONE
@@ -114,6 +121,7 @@ const (
 const (
 	PROXY_HTTP     = C.CURLPROXY_HTTP     /* added in 7.10, new in 7.19.4 default is to use CONNECT HTTP/1.1 */
 	PROXY_HTTP_1_0 = C.CURLPROXY_HTTP_1_0 /* added in 7.19.4, force to use CONNECT HTTP/1.0  */
+	PROXY_HTTPS    = C.CURLPROXY_HTTPS    /* HTTPS proxy, added in 7.52.0 */
 	PROXY_SOCKS4   = C.CURLPROXY_SOCKS4   /* support added in 7.15.2, enum existed already in 7.10 */
 	PROXY_SOCKS5   = C.CURLPROXY_SOCKS5   /* added in 7.10 */
 	PROXY_SOCKS4A  = C.CURLPROXY_SOCKS4A  /* added in 7.18.0 */
@@ -158,9 +166,57 @@ const (
 	IPRESOLVE_V6       = C.CURL_IPRESOLVE_V6
 )
 
+// for ProxyError.SOCKSError and INFO_PROXY_ERROR (CURLproxycode)
+const (
+	PX_OK                               ProxyCode = C.CURLPX_OK
+	PX_BAD_ADDRESS_TYPE                 ProxyCode = C.CURLPX_BAD_ADDRESS_TYPE
+	PX_BAD_VERSION                      ProxyCode = C.CURLPX_BAD_VERSION
+	PX_CLOSED                           ProxyCode = C.CURLPX_CLOSED
+	PX_GSSAPI                           ProxyCode = C.CURLPX_GSSAPI
+	PX_GSSAPI_PERMSG                    ProxyCode = C.CURLPX_GSSAPI_PERMSG
+	PX_GSSAPI_PROTECTION                ProxyCode = C.CURLPX_GSSAPI_PROTECTION
+	PX_IDENTD                           ProxyCode = C.CURLPX_IDENTD
+	PX_IDENTD_DIFFER                    ProxyCode = C.CURLPX_IDENTD_DIFFER
+	PX_LONG_HOSTNAME                    ProxyCode = C.CURLPX_LONG_HOSTNAME
+	PX_LONG_PASSWD                      ProxyCode = C.CURLPX_LONG_PASSWD
+	PX_LONG_USER                        ProxyCode = C.CURLPX_LONG_USER
+	PX_NO_AUTH                          ProxyCode = C.CURLPX_NO_AUTH
+	PX_RECV_ADDRESS                     ProxyCode = C.CURLPX_RECV_ADDRESS
+	PX_RECV_AUTH                        ProxyCode = C.CURLPX_RECV_AUTH
+	PX_RECV_CONNECT                     ProxyCode = C.CURLPX_RECV_CONNECT
+	PX_RECV_REQACK                      ProxyCode = C.CURLPX_RECV_REQACK
+	PX_REPLY_ADDRESS_TYPE_NOT_SUPPORTED ProxyCode = C.CURLPX_REPLY_ADDRESS_TYPE_NOT_SUPPORTED
+	PX_REPLY_COMMAND_NOT_SUPPORTED      ProxyCode = C.CURLPX_REPLY_COMMAND_NOT_SUPPORTED
+	PX_REPLY_CONNECTION_REFUSED         ProxyCode = C.CURLPX_REPLY_CONNECTION_REFUSED
+	PX_REPLY_GENERAL_SERVER_FAILURE     ProxyCode = C.CURLPX_REPLY_GENERAL_SERVER_FAILURE
+	PX_REPLY_HOST_UNREACHABLE           ProxyCode = C.CURLPX_REPLY_HOST_UNREACHABLE
+	PX_REPLY_NETWORK_UNREACHABLE        ProxyCode = C.CURLPX_REPLY_NETWORK_UNREACHABLE
+	PX_REPLY_NOT_ALLOWED                ProxyCode = C.CURLPX_REPLY_NOT_ALLOWED
+	PX_REPLY_TTL_EXPIRED                ProxyCode = C.CURLPX_REPLY_TTL_EXPIRED
+	PX_REPLY_UNASSIGNED                 ProxyCode = C.CURLPX_REPLY_UNASSIGNED
+	PX_REQUEST_FAILED                   ProxyCode = C.CURLPX_REQUEST_FAILED
+	PX_RESOLVE_HOST                     ProxyCode = C.CURLPX_RESOLVE_HOST
+	PX_SEND_AUTH                        ProxyCode = C.CURLPX_SEND_AUTH
+	PX_SEND_CONNECT                     ProxyCode = C.CURLPX_SEND_CONNECT
+	PX_SEND_REQUEST                     ProxyCode = C.CURLPX_SEND_REQUEST
+	PX_UNKNOWN_FAIL                     ProxyCode = C.CURLPX_UNKNOWN_FAIL
+	PX_UNKNOWN_MODE                     ProxyCode = C.CURLPX_UNKNOWN_MODE
+	PX_USER_REJECTED                    ProxyCode = C.CURLPX_USER_REJECTED
+)
+
+// for easy.Setopt(OPT_HEADEROPT, flag)
+const (
+	HEADER_UNIFIED  = C.CURLHEADER_UNIFIED
+	HEADER_SEPARATE = C.CURLHEADER_SEPARATE
+)
+
 // for easy.Setopt(OPT_SSL_OPTIONS, flag)
 const (
-	SSLOPT_ALLOW_BEAST = 1
+	SSLOPT_ALLOW_BEAST        = 1
+	SSLOPT_NO_REVOKE          = C.CURLSSLOPT_NO_REVOKE
+	SSLOPT_NO_PARTIALCHAIN    = C.CURLSSLOPT_NO_PARTIALCHAIN
+	SSLOPT_REVOKE_BEST_EFFORT = C.CURLSSLOPT_REVOKE_BEST_EFFORT
+	SSLOPT_NATIVE_CA          = C.CURLSSLOPT_NATIVE_CA
 )
 
 // for easy.Pause(flat)
@@ -173,6 +229,19 @@ const (
 	PAUSE_CONT      = C.CURLPAUSE_CONT
 )
 
+// for easy.Setopt(OPT_HSTS_CTRL, flag)
+const (
+	HSTS_ENABLE       = C.CURLHSTS_ENABLE
+	HSTS_READONLYFILE = C.CURLHSTS_READONLYFILE
+)
+
+// for OPT_HSTSREADFUNCTION and OPT_HSTSWRITEFUNCTION return values
+const (
+	STS_OK   = C.CURLSTS_OK
+	STS_DONE = C.CURLSTS_DONE
+	STS_FAIL = C.CURLSTS_FAIL
+)
+
 // for multi.Info_read()
 const (
 	CURLMSG_NONE = C.CURLMSG_NONE
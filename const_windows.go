@@ -98,6 +98,13 @@ const (
 	READFUNC_PAUSE  = 0x10000001
 )
 
+// for OPT_SEEKFUNCTION, return a int flag (CURL_SEEKFUNC_*)
+const (
+	SEEKFUNC_OK       = 0
+	SEEKFUNC_FAIL     = 1
+	SEEKFUNC_CANTSEEK = 2
+)
+
 // for easy.Setopt(OPT_HTTP_VERSION, flag) (CURL_HTTP_VERSION_*)
 const (
 	HTTP_VERSION_NONE = 0
@@ -109,6 +116,7 @@ const (
 const (
 	PROXY_HTTP            = 0
 	PROXY_HTTP_1_0        = 1
+	PROXY_HTTPS           = 2
 	PROXY_SOCKS4          = 4
 	PROXY_SOCKS5          = 5
 	PROXY_SOCKS4A         = 6
@@ -152,9 +160,57 @@ const (
 	IPRESOLVE_V6       = 2
 )
 
+// for ProxyError.SOCKSError and INFO_PROXY_ERROR (CURLproxycode)
+const (
+	PX_OK                               ProxyCode = 0
+	PX_BAD_ADDRESS_TYPE                 ProxyCode = 1
+	PX_BAD_VERSION                      ProxyCode = 2
+	PX_CLOSED                           ProxyCode = 3
+	PX_GSSAPI                           ProxyCode = 4
+	PX_GSSAPI_PERMSG                    ProxyCode = 5
+	PX_GSSAPI_PROTECTION                ProxyCode = 6
+	PX_IDENTD                           ProxyCode = 7
+	PX_IDENTD_DIFFER                    ProxyCode = 8
+	PX_LONG_HOSTNAME                    ProxyCode = 9
+	PX_LONG_PASSWD                      ProxyCode = 10
+	PX_LONG_USER                        ProxyCode = 11
+	PX_NO_AUTH                          ProxyCode = 12
+	PX_RECV_ADDRESS                     ProxyCode = 13
+	PX_RECV_AUTH                        ProxyCode = 14
+	PX_RECV_CONNECT                     ProxyCode = 15
+	PX_RECV_REQACK                      ProxyCode = 16
+	PX_REPLY_ADDRESS_TYPE_NOT_SUPPORTED ProxyCode = 17
+	PX_REPLY_COMMAND_NOT_SUPPORTED      ProxyCode = 18
+	PX_REPLY_CONNECTION_REFUSED         ProxyCode = 19
+	PX_REPLY_GENERAL_SERVER_FAILURE     ProxyCode = 20
+	PX_REPLY_HOST_UNREACHABLE           ProxyCode = 21
+	PX_REPLY_NETWORK_UNREACHABLE        ProxyCode = 22
+	PX_REPLY_NOT_ALLOWED                ProxyCode = 23
+	PX_REPLY_TTL_EXPIRED                ProxyCode = 24
+	PX_REPLY_UNASSIGNED                 ProxyCode = 25
+	PX_REQUEST_FAILED                   ProxyCode = 26
+	PX_RESOLVE_HOST                     ProxyCode = 27
+	PX_SEND_AUTH                        ProxyCode = 28
+	PX_SEND_CONNECT                     ProxyCode = 29
+	PX_SEND_REQUEST                     ProxyCode = 30
+	PX_UNKNOWN_FAIL                     ProxyCode = 31
+	PX_UNKNOWN_MODE                     ProxyCode = 32
+	PX_USER_REJECTED                    ProxyCode = 33
+)
+
+// for easy.Setopt(OPT_HEADEROPT, flag) (CURLHEADER_*)
+const (
+	HEADER_UNIFIED  = 0
+	HEADER_SEPARATE = 1
+)
+
 // for easy.Setopt(OPT_SSL_OPTIONS, flag) (CURLSSLOPT_*)
 const (
-	SSLOPT_ALLOW_BEAST = 1
+	SSLOPT_ALLOW_BEAST        = 1
+	SSLOPT_NO_REVOKE          = 2
+	SSLOPT_NO_PARTIALCHAIN    = 4
+	SSLOPT_REVOKE_BEST_EFFORT = 8
+	SSLOPT_NATIVE_CA          = 16
 )
 
 // for easy.Pause(flag) (CURLPAUSE_*)
@@ -167,6 +223,19 @@ const (
 	PAUSE_CONT      = 0
 )
 
+// for easy.Setopt(OPT_HSTS_CTRL, flag) (CURLHSTS_*)
+const (
+	HSTS_ENABLE       = 1 << 0
+	HSTS_READONLYFILE = 1 << 1
+)
+
+// for OPT_HSTSREADFUNCTION and OPT_HSTSWRITEFUNCTION return values (CURLSTS_*)
+const (
+	STS_OK   = 0
+	STS_DONE = 1
+	STS_FAIL = 2
+)
+
 // for multi.Info_read() (CURLMSG_*)
 const (
 	CURLMSG_NONE = 0
@@ -3,7 +3,9 @@
 package curl
 
 /*
+#include <stdint.h>
 #include <stdlib.h>
+#include <string.h>
 #include <curl/curl.h>
 #include "compat.h"
 #include <sys/types.h>
@@ -21,6 +23,17 @@ static CURLcode easy_setopt_slist_helper(CURL *handle, CURLoption option, struct
 static CURLcode easy_setopt_pointer_helper(CURL *handle, CURLoption option, void *parameter) {
     return curl_easy_setopt(handle, option, parameter);
 }
+static CURLcode easy_setopt_userdata_helper(CURL *handle, CURLoption option, uintptr_t userdata) {
+    return curl_easy_setopt(handle, option, (void *)userdata);
+}
+static CURLcode easy_setopt_blob_helper(CURL *handle, CURLoption option, void *data, size_t len) {
+    struct curl_blob blob = { data, len, CURL_BLOB_COPY };
+    return curl_easy_setopt(handle, option, &blob);
+}
+static CURLcode easy_setopt_blob_nocopy_helper(CURL *handle, CURLoption option, void *data, size_t len) {
+    struct curl_blob blob = { data, len, CURL_BLOB_NOCOPY };
+    return curl_easy_setopt(handle, option, &blob);
+}
 static CURLcode easy_setopt_off_t_helper(CURL *handle, CURLoption option, off_t parameter) {
     return curl_easy_setopt(handle, option, parameter);
 }
@@ -37,6 +50,9 @@ static CURLcode easy_getinfo_double_helper(CURL *curl, CURLINFO info, double *p)
 static CURLcode easy_getinfo_slist_helper(CURL *curl, CURLINFO info, struct curl_slist **p) {
     return curl_easy_getinfo(curl, info, p);
 }
+static CURLcode easy_getinfo_off_t_helper(CURL *curl, CURLINFO info, curl_off_t *p) {
+    return curl_easy_getinfo(curl, info, p);
+}
 
 static CURLFORMcode formadd_helper_copyname_copycontents_contentslength(
     struct curl_httppost **httppost, struct curl_httppost **last_post,
@@ -113,11 +129,13 @@ static char* get_protocol_at_index(char **protocols_array, int index) {
 typedef size_t (*c_go_write_callback_t)(char *buffer, size_t size, size_t nitems, void *userdata);
 typedef size_t (*c_go_read_callback_t)(char *buffer, size_t size, size_t nitems, void *instream);
 typedef int (*c_go_xferinfo_callback_t)(void *clientp, curl_off_t dltotal, curl_off_t dlnow, curl_off_t ultotal, curl_off_t ulnow);
+typedef int (*c_go_seek_callback_t)(void *userp, curl_off_t offset, int origin);
 
 extern size_t GoWriteFunctionTrampoline(char *buffer, size_t size, size_t nitems, void *userdata);
 extern size_t GoReadFunctionTrampoline(char *buffer, size_t size, size_t nitems, void *instream);
 extern size_t GoHeaderFunctionTrampoline(char *buffer, size_t size, size_t nitems, void *userdata);
 extern int GoProgressFunctionTrampoline(void *clientp, curl_off_t dltotal, curl_off_t dlnow, curl_off_t ultotal, curl_off_t ulnow);
+extern int GoSeekFunctionTrampoline(void *userp, curl_off_t offset, int origin);
 
 static c_go_write_callback_t get_c_write_callback_ptr() {
     return GoWriteFunctionTrampoline;
@@ -131,6 +149,176 @@ static c_go_write_callback_t get_c_header_callback_ptr() {
 static c_go_xferinfo_callback_t get_c_progress_callback_ptr() {
     return GoProgressFunctionTrampoline;
 }
+static c_go_seek_callback_t get_c_seek_callback_ptr() {
+    return GoSeekFunctionTrampoline;
+}
+
+typedef CURLSTScode (*c_go_hstsread_callback_t)(CURL *easy, struct curl_hstsentry *e, void *userp);
+typedef CURLSTScode (*c_go_hstswrite_callback_t)(CURL *easy, struct curl_hstsentry *e, struct curl_index *i, void *userp);
+
+extern int GoHSTSReadFunctionTrampoline(void *easy, void *entry, void *userp);
+extern int GoHSTSWriteFunctionTrampoline(void *easy, void *entry, void *index, void *userp);
+
+static c_go_hstsread_callback_t get_c_hstsread_callback_ptr() {
+    return (c_go_hstsread_callback_t)GoHSTSReadFunctionTrampoline;
+}
+static c_go_hstswrite_callback_t get_c_hstswrite_callback_ptr() {
+    return (c_go_hstswrite_callback_t)GoHSTSWriteFunctionTrampoline;
+}
+
+// includeSubDomains is a bitfield, which cgo cannot address directly.
+static char *hsts_entry_name(void *e) { return ((struct curl_hstsentry *)e)->name; }
+static size_t hsts_entry_namelen(void *e) { return ((struct curl_hstsentry *)e)->namelen; }
+static int hsts_entry_subdomains(void *e) { return ((struct curl_hstsentry *)e)->includeSubDomains; }
+static char *hsts_entry_expire(void *e) { return ((struct curl_hstsentry *)e)->expire; }
+static void hsts_entry_set(void *e, const char *name, size_t namelen, int subdomains, const char *expire, size_t expirelen) {
+    struct curl_hstsentry *sts = (struct curl_hstsentry *)e;
+    memcpy(sts->name, name, namelen);
+    sts->name[namelen] = 0;
+    sts->includeSubDomains = subdomains ? 1 : 0;
+    memcpy(sts->expire, expire, expirelen);
+    sts->expire[expirelen] = 0;
+}
+static size_t curl_index_index(void *i) { return ((struct curl_index *)i)->index; }
+static size_t curl_index_total(void *i) { return ((struct curl_index *)i)->total; }
+
+typedef int (*c_go_debug_callback_t)(CURL *handle, curl_infotype type, char *data, size_t size, void *userptr);
+
+extern int GoDebugFunctionTrampoline(void *handle, int type, char *data, size_t size, void *userptr);
+
+static c_go_debug_callback_t get_c_debug_callback_ptr() {
+    return (c_go_debug_callback_t)GoDebugFunctionTrampoline;
+}
+
+typedef CURLcode (*c_go_ssl_ctx_callback_t)(CURL *curl, void *ssl_ctx, void *userptr);
+
+extern int GoSSLCtxFunctionTrampoline(void *curl, void *sslctx, void *userptr);
+
+static c_go_ssl_ctx_callback_t get_c_ssl_ctx_callback_ptr() {
+    return (c_go_ssl_ctx_callback_t)GoSSLCtxFunctionTrampoline;
+}
+
+// BoringSSL is linked in but its headers are not shipped, so declare the few
+// functions the SSLContext helpers need.
+typedef struct ssl_st SSL;
+typedef struct ssl_ctx_st SSL_CTX;
+typedef struct x509_st X509;
+typedef struct x509_store_st X509_STORE;
+
+extern X509_STORE *SSL_CTX_get_cert_store(const SSL_CTX *ctx);
+extern int X509_STORE_add_cert(X509_STORE *store, X509 *x509);
+extern X509 *d2i_X509(X509 **out, const unsigned char **inp, long len);
+extern void X509_free(X509 *x509);
+extern SSL_CTX *SSL_get_SSL_CTX(const SSL *ssl);
+extern void SSL_CTX_set_keylog_callback(SSL_CTX *ctx, void (*cb)(const SSL *ssl, const char *line));
+
+typedef void (*go_ex_free_t)(void *parent, void *ptr, void *ad, int index, long argl, void *argp);
+
+extern int SSL_CTX_get_ex_new_index(long argl, void *argp, void *unused, void *dup_unused, go_ex_free_t free_func);
+extern int SSL_CTX_set_ex_data(SSL_CTX *ctx, int idx, void *data);
+extern void *SSL_CTX_get_ex_data(const SSL_CTX *ctx, int idx);
+
+extern void GoSSLKeyLogTrampoline(uintptr_t state, char *line);
+extern void GoSSLCtxStateFree(uintptr_t state);
+
+// ssl_ctx_state_index is the ex_data slot holding the Go state of an
+// SSL_CTX, see ssl_ctx_state_init.
+static int ssl_ctx_state_index = -1;
+
+static void c_ssl_ctx_state_free(void *parent, void *ptr, void *ad, int index, long argl, void *argp) {
+    if (ptr != NULL) GoSSLCtxStateFree((uintptr_t)ptr);
+}
+
+// Allocates the ex_data slot; the state is released when BoringSSL frees
+// the context.
+static int ssl_ctx_state_init(void) {
+    ssl_ctx_state_index = SSL_CTX_get_ex_new_index(0, NULL, NULL, NULL, c_ssl_ctx_state_free);
+    return ssl_ctx_state_index;
+}
+
+static int ssl_ctx_set_state(void *ctx, uintptr_t state) {
+    return SSL_CTX_set_ex_data((SSL_CTX *)ctx, ssl_ctx_state_index, (void *)state);
+}
+
+static int ssl_ctx_add_der_cert(void *ctx, const unsigned char *der, long len) {
+    X509 *x509 = d2i_X509(NULL, &der, len);
+    if (x509 == NULL) return -1;
+    int ok = X509_STORE_add_cert(SSL_CTX_get_cert_store((SSL_CTX *)ctx), x509);
+    X509_free(x509);
+    return ok ? 0 : -2;
+}
+
+static void c_keylog_callback(const SSL *ssl, const char *line) {
+    void *state = SSL_CTX_get_ex_data(SSL_get_SSL_CTX(ssl), ssl_ctx_state_index);
+    if (state != NULL) GoSSLKeyLogTrampoline((uintptr_t)state, (char *)line);
+}
+
+static void ssl_ctx_set_keylog(void *ctx, int enable) {
+    SSL_CTX_set_keylog_callback((SSL_CTX *)ctx, enable ? c_keylog_callback : NULL);
+}
+
+typedef struct x509_store_ctx_st X509_STORE_CTX;
+
+extern int X509_verify_cert(X509_STORE_CTX *ctx);
+extern int X509_STORE_CTX_get_error(X509_STORE_CTX *ctx);
+extern void X509_STORE_CTX_set_error(X509_STORE_CTX *ctx, int err);
+extern X509 *X509_STORE_CTX_get0_cert(X509_STORE_CTX *ctx);
+extern void *X509_STORE_CTX_get0_untrusted(X509_STORE_CTX *ctx);
+extern const char *X509_verify_cert_error_string(long err);
+extern int i2d_X509(X509 *x509, unsigned char **outp);
+extern size_t OPENSSL_sk_num(const void *sk);
+extern void *OPENSSL_sk_value(const void *sk, size_t i);
+extern int SSL_CTX_get_verify_mode(const SSL_CTX *ctx);
+extern void SSL_CTX_set_verify(SSL_CTX *ctx, int mode, int (*cb)(int ok, X509_STORE_CTX *store_ctx));
+extern void SSL_CTX_set_cert_verify_callback(SSL_CTX *ctx, int (*cb)(X509_STORE_CTX *store_ctx, void *arg), void *arg);
+
+#define GO_SSL_VERIFY_PEER 0x01
+#define GO_X509_V_ERR_APPLICATION_VERIFICATION 50
+// GoCertVerifyTrampoline returns GO_VERIFY_TRUSTED when Go verified a chain
+// BoringSSL could not, so that libcurl sees it as verified.
+#define GO_VERIFY_TRUSTED 2
+
+extern int GoCertVerifyTrampoline(void *store, int ok, void *userptr);
+
+// Runs the default chain verification, then lets Go accept or reject.
+static int c_cert_verify_callback(X509_STORE_CTX *store, void *arg) {
+    int ok = X509_verify_cert(store);
+    int keep = GoCertVerifyTrampoline(store, ok, arg);
+    if (keep == GO_VERIFY_TRUSTED) {
+        X509_STORE_CTX_set_error(store, 0);
+        return 1;
+    }
+    if (!keep && X509_STORE_CTX_get_error(store) == 0) {
+        X509_STORE_CTX_set_error(store, GO_X509_V_ERR_APPLICATION_VERIFICATION);
+    }
+    return keep;
+}
+
+// Installs the verify hook and returns whether libcurl asked for peer
+// verification. The hook makes every failure fatal; it accepts unverified
+// chains itself when libcurl would have.
+static int ssl_ctx_set_verify_hook(void *ctx, uintptr_t userptr) {
+    int strict = SSL_CTX_get_verify_mode((SSL_CTX *)ctx) & GO_SSL_VERIFY_PEER;
+    SSL_CTX_set_verify((SSL_CTX *)ctx, GO_SSL_VERIFY_PEER, NULL);
+    SSL_CTX_set_cert_verify_callback((SSL_CTX *)ctx, c_cert_verify_callback, (void *)userptr);
+    return strict;
+}
+
+static const char *verify_store_error(void *store) {
+    return X509_verify_cert_error_string(X509_STORE_CTX_get_error((X509_STORE_CTX *)store));
+}
+
+// verify_store_cert returns the leaf for i == 0 and the certificates the
+// peer sent along with it after that, or NULL past the end.
+static void *verify_store_cert(void *store, size_t i) {
+    if (i == 0) return X509_STORE_CTX_get0_cert((X509_STORE_CTX *)store);
+    void *sk = X509_STORE_CTX_get0_untrusted((X509_STORE_CTX *)store);
+    if (sk == NULL || i - 1 >= OPENSSL_sk_num(sk)) return NULL;
+    return OPENSSL_sk_value(sk, i - 1);
+}
+
+static int x509_der_len(void *x509) { return i2d_X509((X509 *)x509, NULL); }
+static void x509_der(void *x509, unsigned char *out) { i2d_X509((X509 *)x509, &out); }
 
 static CURLMcode multi_wait_helper(CURLM *multi_handle,
                                    struct curl_waitfd extra_fds[],
@@ -147,7 +335,10 @@ static CURLMcode multi_wait_helper(CURLM *multi_handle,
 import "C"
 
 import (
+	"fmt"
+	"runtime/cgo"
 	"strconv"
+	"sync"
 	"unsafe"
 )
 
@@ -322,10 +513,33 @@ func CurlEasySetoptPointer(handle unsafe.Pointer, opt int, val unsafe.Pointer) C
 	return CurlCode(C.easy_setopt_pointer_helper(handle, C.CURLoption(opt), val))
 }
 
+// CurlEasySetoptUserdata sets a pointer option to a value that is not a Go
+// pointer, such as a cgo.Handle.
+func CurlEasySetoptUserdata(handle unsafe.Pointer, opt int, userdata uintptr) CurlCode {
+	return CurlCode(C.easy_setopt_userdata_helper(handle, C.CURLoption(opt), C.uintptr_t(userdata)))
+}
+
 func CurlEasySetoptOffT(handle unsafe.Pointer, opt int, val int64) CurlCode {
 	return CurlCode(C.easy_setopt_off_t_helper(handle, C.CURLoption(opt), C.off_t(val)))
 }
 
+func CurlEasySetoptBlob(handle unsafe.Pointer, opt int, data []byte) CurlCode {
+	cData := C.CBytes(data)
+	defer C.free(cData)
+	return CurlCode(C.easy_setopt_blob_helper(handle, C.CURLoption(opt), cData, C.size_t(len(data))))
+}
+
+// CurlEasySetoptBlobNoCopy makes libcurl use the blob data in place; it must
+// stay valid as long as the handle uses it, see staticBlob.
+func CurlEasySetoptBlobNoCopy(handle unsafe.Pointer, opt int, data unsafe.Pointer, n int) CurlCode {
+	return CurlCode(C.easy_setopt_blob_nocopy_helper(handle, C.CURLoption(opt), data, C.size_t(n)))
+}
+
+// staticBlob copies data to C memory that is never freed.
+func staticBlob(data []byte) unsafe.Pointer {
+	return C.CBytes(data)
+}
+
 func CurlEasySetoptFunction(handle unsafe.Pointer, opt int, funcPtr unsafe.Pointer) CurlCode {
 	return CurlCode(C.easy_setopt_pointer_helper(handle, C.CURLoption(opt), funcPtr))
 }
@@ -374,6 +588,10 @@ func CurlEasyGetinfoSlist(handle unsafe.Pointer, info Info, p unsafe.Pointer) Cu
 	return CurlCode(C.easy_getinfo_slist_helper(handle, C.CURLINFO(info), (**C.struct_curl_slist)(p)))
 }
 
+func CurlEasyGetinfoOffT(handle unsafe.Pointer, info Info, p unsafe.Pointer) CurlCode {
+	return CurlCode(C.easy_getinfo_off_t_helper(handle, C.CURLINFO(info), (*C.curl_off_t)(p)))
+}
+
 func CurlEasyImpersonate(handle unsafe.Pointer, target unsafe.Pointer, defaultHeaders int) CurlCode {
 	return CurlCode(C.curl_easy_impersonate(handle, (*C.char)(target), C.int(defaultHeaders)))
 }
@@ -545,6 +763,7 @@ func GetCurlInfoString() Info   { return Info(C.CURLINFO_STRING) }
 func GetCurlInfoLong() Info     { return Info(C.CURLINFO_LONG) }
 func GetCurlInfoDouble() Info   { return Info(C.CURLINFO_DOUBLE) }
 func GetCurlInfoSList() Info    { return Info(C.CURLINFO_SLIST) }
+func GetCurlInfoOffT() Info     { return Info(C.CURLINFO_OFF_T) }
 
 func GetWriteCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(C.get_c_write_callback_ptr())
@@ -554,6 +773,10 @@ func GetReadCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(C.get_c_read_callback_ptr())
 }
 
+func GetSeekCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(C.get_c_seek_callback_ptr())
+}
+
 func GetHeaderCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(C.get_c_header_callback_ptr())
 }
@@ -562,30 +785,44 @@ func GetProgressCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(C.get_c_progress_callback_ptr())
 }
 
+func GetDebugCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(C.get_c_debug_callback_ptr())
+}
+
+func GetSSLCtxCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(C.get_c_ssl_ctx_callback_ptr())
+}
+
+func GetHSTSReadCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(C.get_c_hstsread_callback_ptr())
+}
+
+func GetHSTSWriteCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(C.get_c_hstswrite_callback_ptr())
+}
+
 //export GoWriteFunctionTrampoline
 func GoWriteFunctionTrampoline(buffer *C.char, size C.size_t, nitems C.size_t, userdata unsafe.Pointer) C.size_t {
-	curlHandle := context_map.Get(uintptr(userdata))
-	if curlHandle == nil || curlHandle.writeFunction == nil {
+	curlHandle := curlFromUserdata(uintptr(userdata))
+	if curlHandle == nil {
 		return 0
 	}
 	bufLen := int(size * nitems)
 	if bufLen == 0 {
 		return 0
 	}
-	var goBuf []byte
-	if bufLen > 0 {
-		goBuf = (*[1 << 30]byte)(unsafe.Pointer(buffer))[:bufLen:bufLen]
-	}
-
-	if (*curlHandle.writeFunction)(goBuf, curlHandle.writeData) {
-		return C.size_t(bufLen)
+	// The slice aliases libcurl's buffer, see Setopt.
+	goBuf := unsafe.Slice((*byte)(unsafe.Pointer(buffer)), bufLen)
+	n, pause := curlHandle.onWrite(goBuf)
+	if pause {
+		return C.CURL_WRITEFUNC_PAUSE
 	}
-	return C.CURL_WRITEFUNC_PAUSE
+	return C.size_t(n)
 }
 
 //export GoReadFunctionTrampoline
 func GoReadFunctionTrampoline(buffer *C.char, size C.size_t, nitems C.size_t, instream unsafe.Pointer) C.size_t {
-	curlHandle := context_map.Get(uintptr(instream))
+	curlHandle := curlFromUserdata(uintptr(instream))
 	if curlHandle == nil || curlHandle.readFunction == nil {
 		return C.CURL_READFUNC_ABORT
 	}
@@ -609,10 +846,19 @@ func GoReadFunctionTrampoline(buffer *C.char, size C.size_t, nitems C.size_t, in
 	return C.size_t(bytesRead)
 }
 
+//export GoSeekFunctionTrampoline
+func GoSeekFunctionTrampoline(userp unsafe.Pointer, offset C.curl_off_t, origin C.int) C.int {
+	curlHandle := curlFromUserdata(uintptr(userp))
+	if curlHandle == nil || curlHandle.seekFunction == nil {
+		return C.CURL_SEEKFUNC_CANTSEEK
+	}
+	return C.int((*curlHandle.seekFunction)(int64(offset), int(origin), curlHandle.seekData))
+}
+
 //export GoHeaderFunctionTrampoline
 func GoHeaderFunctionTrampoline(buffer *C.char, size C.size_t, nitems C.size_t, userdata unsafe.Pointer) C.size_t {
-	curlHandle := context_map.Get(uintptr(userdata)) // userdata is the *CURL pointer
-	if curlHandle == nil || curlHandle.headerFunction == nil {
+	curlHandle := curlFromUserdata(uintptr(userdata))
+	if curlHandle == nil {
 		return 0
 	}
 	bufLen := int(size * nitems)
@@ -624,7 +870,7 @@ func GoHeaderFunctionTrampoline(buffer *C.char, size C.size_t, nitems C.size_t,
 		goBuf = (*[1 << 30]byte)(unsafe.Pointer(buffer))[:bufLen:bufLen]
 	}
 
-	if (*curlHandle.headerFunction)(goBuf, curlHandle.headerData) {
+	if curlHandle.onHeader(goBuf) {
 		return C.size_t(bufLen)
 	}
 	return C.CURL_WRITEFUNC_PAUSE
@@ -632,7 +878,7 @@ func GoHeaderFunctionTrampoline(buffer *C.char, size C.size_t, nitems C.size_t,
 
 //export GoProgressFunctionTrampoline
 func GoProgressFunctionTrampoline(clientp unsafe.Pointer, dltotal C.curl_off_t, dlnow C.curl_off_t, ultotal C.curl_off_t, ulnow C.curl_off_t) C.int {
-	curlHandle := context_map.Get(uintptr(clientp))
+	curlHandle := curlFromUserdata(uintptr(clientp))
 	if curlHandle == nil || curlHandle.progressFunction == nil {
 		return 0
 	}
@@ -646,3 +892,145 @@ func GoProgressFunctionTrampoline(clientp unsafe.Pointer, dltotal C.curl_off_t,
 	}
 	return 1
 }
+
+//export GoDebugFunctionTrampoline
+func GoDebugFunctionTrampoline(handle unsafe.Pointer, infoType C.int, data *C.char, size C.size_t, userptr unsafe.Pointer) C.int {
+	curlHandle := curlFromUserdata(uintptr(userptr))
+	if curlHandle == nil || curlHandle.debugFunction == nil {
+		return 0
+	}
+	var goBuf []byte
+	if size > 0 {
+		goBuf = unsafe.Slice((*byte)(unsafe.Pointer(data)), int(size))
+	}
+	(*curlHandle.debugFunction)(Info(infoType), goBuf, curlHandle.debugData)
+	return 0
+}
+
+//export GoSSLCtxFunctionTrampoline
+func GoSSLCtxFunctionTrampoline(curl unsafe.Pointer, sslctx unsafe.Pointer, userptr unsafe.Pointer) C.int {
+	curlHandle := curlFromUserdata(uintptr(userptr))
+	if curlHandle == nil {
+		return C.CURLE_OK
+	}
+	return C.int(curlHandle.sslCtx(sslctx))
+}
+
+//export GoSSLKeyLogTrampoline
+func GoSSLKeyLogTrampoline(state C.uintptr_t, line *C.char) {
+	if s := sslCtxStateFrom(uintptr(state)); s != nil && s.keyLog != nil {
+		s.keyLog(C.GoString(line))
+	}
+}
+
+//export GoSSLCtxStateFree
+func GoSSLCtxStateFree(state C.uintptr_t) {
+	cgo.Handle(state).Delete()
+}
+
+//export GoCertVerifyTrampoline
+func GoCertVerifyTrampoline(store unsafe.Pointer, ok C.int, userptr unsafe.Pointer) C.int {
+	s := sslCtxStateFrom(uintptr(userptr))
+	if s == nil {
+		return 0
+	}
+	var verifyErr error
+	if ok != 1 {
+		verifyErr = fmt.Errorf("curl: SSL certificate problem: %s", C.GoString(C.verify_store_error(store)))
+	}
+	var ders [][]byte
+	for i := 0; ; i++ {
+		x := C.verify_store_cert(store, C.size_t(i))
+		if x == nil {
+			break
+		}
+		n := C.x509_der_len(x)
+		if n <= 0 {
+			continue
+		}
+		der := make([]byte, int(n))
+		C.x509_der(x, (*C.uchar)(unsafe.Pointer(&der[0])))
+		ders = append(ders, der)
+	}
+	return C.int(s.verifyPeer(ders, verifyErr))
+}
+
+var sslCtxStateIndex struct {
+	once sync.Once
+	ok   bool
+}
+
+func sslCtxSetState(ctx unsafe.Pointer, state cgo.Handle) error {
+	sslCtxStateIndex.once.Do(func() {
+		sslCtxStateIndex.ok = C.ssl_ctx_state_init() >= 0
+	})
+	if !sslCtxStateIndex.ok {
+		return fmt.Errorf("curl: failed to allocate SSL context ex_data index")
+	}
+	if C.ssl_ctx_set_state(ctx, C.uintptr_t(state)) != 1 {
+		return fmt.Errorf("curl: failed to set SSL context ex_data")
+	}
+	return nil
+}
+
+func sslCtxSetVerify(ctx unsafe.Pointer, state cgo.Handle) (strict bool, err error) {
+	return C.ssl_ctx_set_verify_hook(ctx, C.uintptr_t(state)) != 0, nil
+}
+
+func sslCtxAddCertDER(ctx unsafe.Pointer, der []byte) error {
+	if len(der) == 0 {
+		return fmt.Errorf("curl: empty certificate")
+	}
+	cDer := C.CBytes(der)
+	defer C.free(cDer)
+	switch C.ssl_ctx_add_der_cert(ctx, (*C.uchar)(cDer), C.long(len(der))) {
+	case -1:
+		return fmt.Errorf("curl: failed to parse certificate")
+	case -2:
+		return fmt.Errorf("curl: failed to add certificate to the SSL context store")
+	}
+	return nil
+}
+
+func sslCtxSetKeyLog(ctx unsafe.Pointer, enable bool) error {
+	var cEnable C.int
+	if enable {
+		cEnable = 1
+	}
+	C.ssl_ctx_set_keylog(ctx, cEnable)
+	return nil
+}
+
+//export GoHSTSReadFunctionTrampoline
+func GoHSTSReadFunctionTrampoline(easy unsafe.Pointer, entry unsafe.Pointer, userp unsafe.Pointer) C.int {
+	curlHandle := curlFromUserdata(uintptr(userp))
+	if curlHandle == nil {
+		return C.CURLSTS_FAIL
+	}
+	host, includeSubDomains, expire, status := curlHandle.hstsRead(int(C.hsts_entry_namelen(entry)))
+	if status != STS_OK {
+		return C.int(status)
+	}
+	var sub C.int
+	if includeSubDomains {
+		sub = 1
+	}
+	cHost := C.CString(host)
+	defer C.free(unsafe.Pointer(cHost))
+	cExpire := C.CString(expire)
+	defer C.free(unsafe.Pointer(cExpire))
+	C.hsts_entry_set(entry, cHost, C.size_t(len(host)), sub, cExpire, C.size_t(len(expire)))
+	return C.CURLSTS_OK
+}
+
+//export GoHSTSWriteFunctionTrampoline
+func GoHSTSWriteFunctionTrampoline(easy unsafe.Pointer, entry unsafe.Pointer, index unsafe.Pointer, userp unsafe.Pointer) C.int {
+	curlHandle := curlFromUserdata(uintptr(userp))
+	if curlHandle == nil {
+		return C.CURLSTS_FAIL
+	}
+	host := C.GoString(C.hsts_entry_name(entry))
+	expire := C.GoString(C.hsts_entry_expire(entry))
+	sub := C.hsts_entry_subdomains(entry) != 0
+	return C.int(curlHandle.hstsWrite(host, sub, expire, int(C.curl_index_index(index)), int(C.curl_index_total(index))))
+}
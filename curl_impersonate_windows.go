@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"runtime/cgo"
 	"strconv"
 	"sync"
 	"syscall"
@@ -78,9 +79,14 @@ var (
 	procCurlShareSetopt   *syscall.Proc
 	procCurlShareStrerror *syscall.Proc
 
-	readCallbackFuncptr   uintptr
-	writeCallbackFuncptr  uintptr
-	headerCallbackFuncptr uintptr
+	readCallbackFuncptr      uintptr
+	seekCallbackFuncptr      uintptr
+	writeCallbackFuncptr     uintptr
+	headerCallbackFuncptr    uintptr
+	hstsReadCallbackFuncptr  uintptr
+	hstsWriteCallbackFuncptr uintptr
+	debugCallbackFuncptr     uintptr
+	sslCtxCallbackFuncptr    uintptr
 
 	offsetCurlMsg_msg         = 0
 	offsetCurlMsg_easy_handle = 8
@@ -102,7 +108,7 @@ func loadProcedures() {
 		}
 
 		if err := windows.SetDllDirectory(cacheDir); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to set DLL directory: %v\n", err)
+			warnf("curl: failed to set DLL directory: %v", err)
 		}
 
 		hasher := sha256.New()
@@ -197,16 +203,23 @@ func initializeCgoCallbacks() {
 		} else {
 			loadErr = fmt.Errorf("%w; %v", loadErr, err)
 		}
-		fmt.Fprintln(os.Stderr, "Error: CGO Progress callback pointer is zero after initialization.")
+		errorf("curl: cgo progress callback pointer is zero after initialization")
 	}
 }
 
 func initializeNonFloatSyscallCallbacks() {
 	writeCallbackFuncptr = syscall.NewCallback(goWriteFunctionTrampoline)
 	readCallbackFuncptr = syscall.NewCallback(goReadFunctionTrampoline)
+	seekCallbackFuncptr = syscall.NewCallback(goSeekFunctionTrampoline)
 	headerCallbackFuncptr = syscall.NewCallback(goHeaderFunctionTrampoline)
-
-	if writeCallbackFuncptr == 0 || readCallbackFuncptr == 0 || headerCallbackFuncptr == 0 {
+	hstsReadCallbackFuncptr = syscall.NewCallback(goHSTSReadFunctionTrampoline)
+	hstsWriteCallbackFuncptr = syscall.NewCallback(goHSTSWriteFunctionTrampoline)
+	debugCallbackFuncptr = syscall.NewCallback(goDebugFunctionTrampoline)
+	sslCtxCallbackFuncptr = syscall.NewCallback(goSSLCtxFunctionTrampoline)
+
+	if writeCallbackFuncptr == 0 || readCallbackFuncptr == 0 || seekCallbackFuncptr == 0 || headerCallbackFuncptr == 0 ||
+		hstsReadCallbackFuncptr == 0 || hstsWriteCallbackFuncptr == 0 || debugCallbackFuncptr == 0 ||
+		sslCtxCallbackFuncptr == 0 {
 		err := fmt.Errorf("failed to create one or more essential non-float syscall callbacks for libcurl")
 		if loadErr == nil {
 			loadErr = err
@@ -220,7 +233,7 @@ func init() {
 	onceLoad.Do(func() {
 		loadProcedures()
 		if loadErr != nil {
-			fmt.Fprintf(os.Stderr, "curl_impersonate_windows: DLL loading failed: %v\n", loadErr)
+			errorf("curl: DLL loading failed: %v", loadErr)
 		} else {
 			onceCgoProgressCallback.Do(initializeCgoCallbacks)
 			initializeNonFloatSyscallCallbacks()
@@ -240,7 +253,7 @@ func goString(ccharPtr uintptr) string {
 		}
 		length++
 		if length > (1 << 20) {
-			fmt.Fprintf(os.Stderr, "goString: C string too long or not null-terminated at %p\n", ptr)
+			warnf("curl: C string too long or not null-terminated at %p", ptr)
 			return ""
 		}
 	}
@@ -469,9 +482,46 @@ func CurlEasySetoptSlist(handle unsafe.Pointer, opt int, slist CurlSlist) CurlCo
 func CurlEasySetoptPointer(handle unsafe.Pointer, opt int, ptr unsafe.Pointer) CurlCode {
 	return curlEasySetoptRaw(handle, opt, uintptr(ptr))
 }
+func CurlEasySetoptUserdata(handle unsafe.Pointer, opt int, userdata uintptr) CurlCode {
+	return curlEasySetoptRaw(handle, opt, userdata)
+}
 func CurlEasySetoptOffT(handle unsafe.Pointer, opt int, val int64) CurlCode {
 	return curlEasySetoptRaw(handle, opt, uintptr(val))
 }
+
+// curlBlob mirrors struct curl_blob.
+type curlBlob struct {
+	data  unsafe.Pointer
+	len   uintptr
+	flags uint32
+}
+
+const curlBlobCopy = 1
+
+func CurlEasySetoptBlob(handle unsafe.Pointer, opt int, data []byte) CurlCode {
+	blob := curlBlob{data: unsafe.Pointer(&data[0]), len: uintptr(len(data)), flags: curlBlobCopy}
+	code := curlEasySetoptRaw(handle, opt, uintptr(unsafe.Pointer(&blob)))
+	runtime.KeepAlive(&blob)
+	runtime.KeepAlive(data)
+	return code
+}
+
+// CurlEasySetoptBlobNoCopy makes libcurl use the blob data in place; it must
+// stay valid as long as the handle uses it, see staticBlob.
+func CurlEasySetoptBlobNoCopy(handle unsafe.Pointer, opt int, data unsafe.Pointer, n int) CurlCode {
+	blob := curlBlob{data: data, len: uintptr(n)}
+	code := curlEasySetoptRaw(handle, opt, uintptr(unsafe.Pointer(&blob)))
+	runtime.KeepAlive(&blob)
+	return code
+}
+
+// staticBlob returns data in place. Without cgo there is no C heap, but the
+// Go heap does not move objects, so data only has to be referenced for the
+// life of the process, as package-level data is.
+func staticBlob(data []byte) unsafe.Pointer {
+	return unsafe.Pointer(&data[0])
+}
+
 func CurlEasySetoptFunction(handle unsafe.Pointer, opt int, funcPtr unsafe.Pointer) CurlCode {
 	return curlEasySetoptRaw(handle, opt, uintptr(funcPtr))
 }
@@ -535,6 +585,9 @@ func CurlEasyGetinfoDouble(handle unsafe.Pointer, info Info, p unsafe.Pointer) C
 func CurlEasyGetinfoSlist(handle unsafe.Pointer, info Info, p unsafe.Pointer) CurlCode {
 	return curlEasyGetinfoRaw(handle, info, p)
 }
+func CurlEasyGetinfoOffT(handle unsafe.Pointer, info Info, p unsafe.Pointer) CurlCode {
+	return curlEasyGetinfoRaw(handle, info, p)
+}
 func CurlEasyImpersonate(handle unsafe.Pointer, target unsafe.Pointer, defaultHeaders int) CurlCode {
 	if procCurlEasyImpersonate == nil || handle == nil {
 		return E_BAD_FUNCTION_ARGUMENT
@@ -775,6 +828,7 @@ func GetCurlInfoString() Info   { return INFO_STRING }
 func GetCurlInfoLong() Info     { return INFO_LONG }
 func GetCurlInfoDouble() Info   { return INFO_DOUBLE }
 func GetCurlInfoSList() Info    { return INFO_SLIST }
+func GetCurlInfoOffT() Info     { return INFO_OFF_T }
 
 func GetWriteCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(writeCallbackFuncptr)
@@ -782,24 +836,40 @@ func GetWriteCallbackFuncptr() unsafe.Pointer {
 func GetReadCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(readCallbackFuncptr)
 }
+
+func GetSeekCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(seekCallbackFuncptr)
+}
 func GetHeaderCallbackFuncptr() unsafe.Pointer {
 	return unsafe.Pointer(headerCallbackFuncptr)
 }
+func GetDebugCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(debugCallbackFuncptr)
+}
+func GetSSLCtxCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(sslCtxCallbackFuncptr)
+}
+func GetHSTSReadCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(hstsReadCallbackFuncptr)
+}
+func GetHSTSWriteCallbackFuncptr() unsafe.Pointer {
+	return unsafe.Pointer(hstsWriteCallbackFuncptr)
+}
 func GetProgressCallbackFuncptr() unsafe.Pointer {
 	if cgoProgressCallbackFuncptr == 0 {
 		onceCgoProgressCallback.Do(initializeCgoCallbacks)
 		if cgoProgressCallbackFuncptr == 0 && loadErr == nil {
-			fmt.Fprintln(os.Stderr, "Warning: CGO Progress callback pointer is zero; initialization failed.")
+			warnf("curl: cgo progress callback pointer is zero; initialization failed")
 		} else if cgoProgressCallbackFuncptr == 0 && loadErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: CGO Progress callback pointer is zero due to: %v\n", loadErr)
+			warnf("curl: cgo progress callback pointer is zero due to: %v", loadErr)
 		}
 	}
 	return unsafe.Pointer(cgoProgressCallbackFuncptr)
 }
 
 func goWriteFunctionTrampoline(ptr, size, nmemb, userdata uintptr) uintptr {
-	curl := context_map.Get(userdata)
-	if curl == nil || curl.writeFunction == nil {
+	curl := curlFromUserdata(userdata)
+	if curl == nil {
 		return 0
 	}
 	bufLen := int(size * nmemb)
@@ -807,14 +877,15 @@ func goWriteFunctionTrampoline(ptr, size, nmemb, userdata uintptr) uintptr {
 		return 0
 	}
 	buf := unsafe.Slice((*byte)(unsafe.Pointer(ptr)), bufLen)
-	if (*curl.writeFunction)(buf, curl.writeData) {
-		return uintptr(bufLen)
+	n, pause := curl.onWrite(buf)
+	if pause {
+		return uintptr(WRITEFUNC_PAUSE)
 	}
-	return uintptr(WRITEFUNC_PAUSE)
+	return uintptr(n)
 }
 
 func goReadFunctionTrampoline(buffer, size, nitems, instream uintptr) uintptr {
-	curl := context_map.Get(instream)
+	curl := curlFromUserdata(instream)
 	if curl == nil || curl.readFunction == nil {
 		return uintptr(READFUNC_ABORT)
 	}
@@ -830,9 +901,18 @@ func goReadFunctionTrampoline(buffer, size, nitems, instream uintptr) uintptr {
 	return uintptr(bytesWrittenByGoFunc)
 }
 
+// curl_off_t fits a register on the 64-bit targets the DLL is built for.
+func goSeekFunctionTrampoline(userp, offset, origin uintptr) uintptr {
+	curl := curlFromUserdata(userp)
+	if curl == nil || curl.seekFunction == nil {
+		return uintptr(SEEKFUNC_CANTSEEK)
+	}
+	return uintptr((*curl.seekFunction)(int64(offset), int(origin), curl.seekData))
+}
+
 func goHeaderFunctionTrampoline(buffer, size, nitems, userdata uintptr) uintptr {
-	curl := context_map.Get(userdata)
-	if curl == nil || curl.headerFunction == nil {
+	curl := curlFromUserdata(userdata)
+	if curl == nil {
 		return 0
 	}
 	bufLen := int(size * nitems)
@@ -840,8 +920,100 @@ func goHeaderFunctionTrampoline(buffer, size, nitems, userdata uintptr) uintptr
 		return 0
 	}
 	buf := unsafe.Slice((*byte)(unsafe.Pointer(buffer)), bufLen)
-	if (*curl.headerFunction)(buf, curl.headerData) {
+	if curl.onHeader(buf) {
 		return uintptr(bufLen)
 	}
 	return uintptr(WRITEFUNC_PAUSE)
 }
+
+func goDebugFunctionTrampoline(handle, infoType, data, size, userptr uintptr) uintptr {
+	curl := curlFromUserdata(userptr)
+	if curl == nil || curl.debugFunction == nil {
+		return 0
+	}
+	var buf []byte
+	if size > 0 {
+		buf = unsafe.Slice((*byte)(unsafe.Pointer(data)), int(size))
+	}
+	(*curl.debugFunction)(Info(infoType), buf, curl.debugData)
+	return 0
+}
+
+func goSSLCtxFunctionTrampoline(curlp, sslctx, userptr uintptr) uintptr {
+	curl := curlFromUserdata(userptr)
+	if curl == nil {
+		return uintptr(E_OK)
+	}
+	return uintptr(curl.sslCtx(unsafe.Pointer(sslctx)))
+}
+
+// The bundled DLL links BoringSSL statically without exporting it, so the
+// SSLContext helpers cannot reach it on Windows.
+func sslCtxAddCertDER(ctx unsafe.Pointer, der []byte) error {
+	return fmt.Errorf("curl: SSL context certificate store is not accessible on windows")
+}
+
+func sslCtxSetState(ctx unsafe.Pointer, state cgo.Handle) error {
+	return fmt.Errorf("curl: SSL context state is not available on windows")
+}
+
+func sslCtxSetVerify(ctx unsafe.Pointer, state cgo.Handle) (strict bool, err error) {
+	return false, fmt.Errorf("curl: certificate verification hooks are not available on windows")
+}
+
+func sslCtxSetKeyLog(ctx unsafe.Pointer, enable bool) error {
+	return fmt.Errorf("curl: SSL context key logging is not available on windows")
+}
+
+// curlHSTSEntry mirrors struct curl_hstsentry; includeSubDomains is bit 0 of flags.
+type curlHSTSEntry struct {
+	name    uintptr
+	namelen uintptr
+	flags   uint32
+	expire  [18]byte
+}
+
+// curlIndex mirrors struct curl_index.
+type curlIndex struct {
+	index uintptr
+	total uintptr
+}
+
+func goHSTSReadFunctionTrampoline(easy, entry, userp uintptr) uintptr {
+	curl := curlFromUserdata(userp)
+	if curl == nil {
+		return STS_FAIL
+	}
+	sts := (*curlHSTSEntry)(unsafe.Pointer(entry))
+	host, includeSubDomains, expire, status := curl.hstsRead(int(sts.namelen))
+	if status != STS_OK {
+		return uintptr(status)
+	}
+	name := unsafe.Slice((*byte)(unsafe.Pointer(sts.name)), sts.namelen)
+	copy(name, host)
+	name[len(host)] = 0
+	sts.flags &^= 1
+	if includeSubDomains {
+		sts.flags |= 1
+	}
+	n := copy(sts.expire[:len(sts.expire)-1], expire)
+	sts.expire[n] = 0
+	return STS_OK
+}
+
+func goHSTSWriteFunctionTrampoline(easy, entry, index, userp uintptr) uintptr {
+	curl := curlFromUserdata(userp)
+	if curl == nil {
+		return STS_FAIL
+	}
+	sts := (*curlHSTSEntry)(unsafe.Pointer(entry))
+	idx := (*curlIndex)(unsafe.Pointer(index))
+	expire := sts.expire[:]
+	for i, b := range expire {
+		if b == 0 {
+			expire = expire[:i]
+			break
+		}
+	}
+	return uintptr(curl.hstsWrite(goString(sts.name), sts.flags&1 != 0, string(expire), int(idx.index), int(idx.total)))
+}
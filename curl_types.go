@@ -10,6 +10,7 @@ type (
 	CurlError   uint32
 	CurlCode    uint32
 	Code        uint32
+	ProxyCode   uint32
 )
 
 type (
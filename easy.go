@@ -6,13 +6,16 @@ package curl
 import "C"
 
 import (
+	"crypto/x509"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime"
 	"os"
 	"path"
 	"runtime"
-	"sync"
+	"runtime/cgo"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -36,71 +39,97 @@ func newCurlError(errno CurlCode) error {
 // curl_easy interface
 type CURL struct {
 	handle                                        unsafe.Pointer
+	self                                          cgo.Handle
+	private                                       any
+	id                                            uint64
+	url                                           string
+	customRequest, impliedMethod                  string
+	httpHeader                                    []string
+	unrestrictedAuth                              bool
+	sslOptions, proxySSLOptions                   int64
+	impersonateTarget                             string
+	impersonateHeaders                            bool
+	baseHeader, sentHeader                        Header
+	capturingSent                                 bool
+	servedInfo                                    map[Info]any
+	slogger                                       *slog.Logger
 	headerFunction                                *func([]byte, any) bool
 	writeFunction                                 *func([]byte, any) bool
+	writer                                        io.Writer
 	readFunction                                  *func([]byte, any) int
+	seekFunction                                  *func(int64, int, any) int
 	progressFunction                              *func(float64, float64, float64, float64, any) bool
+	hstsReadFunction                              *func(any) (HSTSEntry, bool)
+	hstsWriteFunction                             *func(HSTSEntry, int, int, any) bool
+	debugFunction                                 *func(Info, []byte, any)
+	verbose, verboseDebug                         bool
+	sslCtxFunction                                *func(*SSLContext, any) error
+	hooks                                         []*transferHook
+	headerTaps                                    []*func([]byte)
+	writeTaps                                     []*func([]byte)
+	holdResponse                                  bool
+	proxy                                         string
+	connectHeaders                                *connectHeaders
+	redirect                                      *redirectState
+	errorBuffer                                   unsafe.Pointer
+	errorBufferKeep                               []byte
 	headerData, writeData, readData, progressData any
+	seekData                                      any
+	hstsReadData, hstsWriteData, debugData        any
+	sslCtxData                                    any
+	keyLogWriter                                  *keyLogWriter
+	verifyFunction                                VerifyPeerFunc
+	verifyHook                                    *transferHook
+	verifyErr                                     error
+	caPool                                        *x509.CertPool
 	mallocAllocs                                  []unsafe.Pointer
 }
 
-type contextMap struct {
-	items map[uintptr]*CURL
-	sync.RWMutex
-}
-
-func (c *contextMap) Set(k uintptr, v *CURL) {
-	c.Lock()
-	defer c.Unlock()
-
-	c.items[k] = v
-}
-
-func (c *contextMap) Get(k uintptr) *CURL {
-	c.RLock()
-	v := c.items[k]
-	c.RUnlock()
-	return v
-}
-
-func (c *contextMap) Delete(k uintptr) {
-	c.Lock()
-	defer c.Unlock()
-
-	delete(c.items, k)
-}
+var handleSeq atomic.Uint64
 
-var context_map = &contextMap{
-	items: make(map[uintptr]*CURL),
+func newCURL(p unsafe.Pointer) *CURL {
+	c := &CURL{handle: p, id: handleSeq.Add(1), mallocAllocs: make([]unsafe.Pointer, 0)}
+	c.self = cgo.NewHandle(c)
+	if errCode := c.setUserdata(OPT_PRIVATE); errCode != E_OK {
+		c.logAttrs(_ERROR, "curl: failed to set private handle data", slog.Any("error", newCurlError(errCode)))
+	}
+	if err := c.setupErrorBuffer(); err != nil {
+		c.logAttrs(_ERROR, "curl: failed to set error buffer", slog.Any("error", err))
+	}
+	return c
 }
 
 // curl_easy_init - Start a libcurl easy session
+//
+// It panics if libcurl cannot create a handle; use NewEasy to get an error
+// instead.
 func EasyInit() *CURL {
-	certPath, err := getEmbeddedCACertPath()
+	c, err := initEasy()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Could not prepare embedded CA certificate: %v. SSL connections may fail.\n", err)
+		panic(err)
 	}
+	if err := c.SetCASource(EmbeddedCAs()); err != nil {
+		c.logAttrs(_ERROR, "curl: failed to set embedded CA bundle, SSL connections may fail", slog.Any("error", err))
+	}
+	return c
+}
 
+func initEasy() (*CURL, error) {
 	p := CurlEasyInit()
 	if p == nil {
 		if runtime.GOOS == "windows" && CheckLoad() != nil {
-			panic(fmt.Errorf("curl: EasyInit failed because DLL could not be loaded: %v", CheckLoad()))
+			return nil, fmt.Errorf("curl: EasyInit failed because DLL could not be loaded: %w", CheckLoad())
 		}
-		panic("curl: EasyInit returned a nil handle")
+		return nil, fmt.Errorf("curl: EasyInit returned a nil handle")
 	}
-	c := &CURL{handle: p, mallocAllocs: make([]unsafe.Pointer, 0)}
-	context_map.Set(uintptr(p), c)
-
-	if certPath != "" {
-		err = c.Setopt(OPT_CAINFO, certPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Failed to set CURLOPT_CAINFO to '%s': %v. SSL connections may fail.\n", certPath, err)
+	c := newCURL(p)
+	c.logAttrs(_DEBUG, "curl: easy handle created")
+	if globalKeyLog.Load() != nil {
+		if err := c.installSSLCtxCallback(); err != nil {
+			c.logAttrs(_ERROR, "curl: failed to enable TLS key logging", slog.Any("error", err))
 		}
-	} else {
-		fmt.Fprintln(os.Stderr, "Error: Embedded CA certificate path is empty. CURLOPT_CAINFO not set.")
 	}
-
-	return c
+	return c, nil
 }
 
 // curl_easy_duphandle - Clone a libcurl session handle
@@ -112,24 +141,96 @@ func (curl *CURL) Duphandle() *CURL {
 	if p == nil {
 		panic("curl: Duphandle returned a nil handle")
 	}
-	c := &CURL{handle: p, mallocAllocs: make([]unsafe.Pointer, 0)}
-	context_map.Set(uintptr(p), c)
+	c := newCURL(p)
+	c.url = curl.url
+	c.customRequest = curl.customRequest
+	c.impliedMethod = curl.impliedMethod
+	c.httpHeader = curl.httpHeader
+	c.unrestrictedAuth = curl.unrestrictedAuth
+	c.impersonateTarget = curl.impersonateTarget
+	c.impersonateHeaders = curl.impersonateHeaders
+	c.baseHeader = curl.baseHeader
+	c.verbose = curl.verbose
+	c.caPool = curl.caPool
+	c.sslOptions, c.proxySSLOptions = curl.sslOptions, curl.proxySSLOptions
+	c.slogger = curl.slogger
+	c.copyCallbacks(curl)
+	c.logAttrs(_DEBUG, "curl: easy handle duplicated", slog.Uint64("parent", curl.id))
 	return c
 }
 
+// copyCallbacks gives a duplicate the Go callbacks of its parent. libcurl
+// copied the parent's userdata along with the trampolines, so point it at
+// the duplicate; otherwise its callbacks would fail once the parent is
+// cleaned up. Transfer hooks and header taps belong to the parent's helpers
+// and are not copied.
+func (curl *CURL) copyCallbacks(parent *CURL) {
+	curl.headerFunction, curl.headerData = parent.headerFunction, parent.headerData
+	curl.writeFunction, curl.writer, curl.writeData = parent.writeFunction, parent.writer, parent.writeData
+	curl.readFunction, curl.readData = parent.readFunction, parent.readData
+	curl.seekFunction, curl.seekData = parent.seekFunction, parent.seekData
+	curl.progressFunction, curl.progressData = parent.progressFunction, parent.progressData
+	curl.hstsReadFunction, curl.hstsReadData = parent.hstsReadFunction, parent.hstsReadData
+	curl.hstsWriteFunction, curl.hstsWriteData = parent.hstsWriteFunction, parent.hstsWriteData
+	curl.debugFunction, curl.debugData = parent.debugFunction, parent.debugData
+	if parent.verboseDebug {
+		// The parent's verbose function is bound to the parent and would log
+		// under its id and logger.
+		f := curl.verboseDebugFunction
+		curl.debugFunction, curl.verboseDebug = &f, true
+	}
+	curl.sslCtxFunction, curl.sslCtxData = parent.sslCtxFunction, parent.sslCtxData
+	curl.keyLogWriter = parent.keyLogWriter
+	curl.private = parent.private
+
+	// These are only read by their callbacks, so pointing them at the
+	// handle is harmless when no callback is set.
+	opts := []EasyOpt{OPT_XFERINFODATA, OPT_SEEKDATA, OPT_DEBUGDATA, OPT_SSL_CTX_DATA, OPT_HSTSREADDATA, OPT_HSTSWRITEDATA}
+	// The default callbacks of these take a FILE *.
+	if parent.writeFunction != nil || parent.writer != nil || len(parent.writeTaps) > 0 {
+		opts = append(opts, OPT_WRITEDATA)
+	}
+	if parent.readFunction != nil {
+		opts = append(opts, OPT_READDATA)
+	}
+	if parent.headerFunction != nil || len(parent.headerTaps) > 0 {
+		opts = append(opts, OPT_HEADERDATA)
+	}
+	for _, opt := range opts {
+		if errCode := curl.setUserdata(opt); errCode != E_OK {
+			curl.logAttrs(_ERROR, "curl: failed to set callback data of duplicate", slog.Any("error", newCurlError(errCode)))
+		}
+	}
+}
+
 // curl_easy_cleanup - End a libcurl easy session
 func (curl *CURL) Cleanup() {
 	p := curl.handle
 	if p != nil {
+		curl.dropTransferHooks()
 		CurlEasyCleanup(p)
 		curl.MallocFreeAfter(0)
-		context_map.Delete(uintptr(p))
+		// libcurl makes no more callbacks, so the handle can go.
+		curl.self.Delete()
+		curl.freeErrorBuffer()
 		curl.handle = nil
+		curl.logAttrs(_DEBUG, "curl: easy handle cleaned up")
 	}
 }
 
 // curl_easy_setopt - set options for a curl easy handle
 // WARNING: a function pointer is &fun, but function addr is reflect.ValueOf(fun).Pointer()
+//
+// The byte slices passed to WRITEFUNCTION, HEADERFUNCTION, READFUNCTION and
+// DEBUGFUNCTION point into libcurl's buffer and are only valid until the
+// callback returns; copy what has to be kept. An io.Writer set as WRITEDATA
+// without a WRITEFUNCTION receives the body directly and must not keep the
+// slice either, as io.Writer requires.
+//
+// libcurl only calls DEBUGFUNCTION in verbose mode, so setting it turns on
+// OPT_VERBOSE as well. Clearing it turns verbose mode off again, unless
+// OPT_VERBOSE was set explicitly; then verbose output goes back to the
+// handle's logger.
 func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 	p := curl.handle
 	if p == nil {
@@ -138,16 +239,24 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 
 	switch opt {
 	case OPT_WRITEDATA:
+		if param == nil {
+			curl.writeData = nil
+			curl.writer = nil
+			return nil
+		}
 		w, ok := param.(io.Writer)
 		if !ok {
 			return fmt.Errorf("curl: expected io.Writer for WRITEDATA, got %T", param)
 		}
 		curl.writeData = w
-		return newCurlError(CurlEasySetoptPointer(p, int(opt), unsafe.Pointer(p)))
+		curl.writer = w
+		return curl.installWriteCallback()
 	case OPT_WRITEFUNCTION:
 		if param == nil {
 			curl.writeFunction = nil
-			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
+			// The trampoline goes on writing to WRITEDATA or stdout, as
+			// libcurl's default does.
+			return curl.installWriteCallback()
 		}
 		f, ok := param.(func([]byte, any) bool)
 		if !ok {
@@ -157,12 +266,7 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 			)
 		}
 		curl.writeFunction = &f
-		if errCode := CurlEasySetoptPointer(p, int(OPT_WRITEDATA), unsafe.Pointer(p)); errCode != 0 {
-			return newCurlError(errCode)
-		}
-		return newCurlError(
-			CurlEasySetoptFunction(p, int(opt), GetWriteCallbackFuncptr()),
-		)
+		return curl.installWriteCallback()
 
 	case OPT_READFUNCTION:
 		if param == nil {
@@ -176,15 +280,36 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 		}
 		curl.readFunction = &f
 
-		if errCode := CurlEasySetoptPointer(p, int(OPT_READDATA), unsafe.Pointer(p)); errCode != 0 {
+		if errCode := curl.setUserdata(OPT_READDATA); errCode != 0 {
 			return newCurlError(errCode)
 		}
 		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetReadCallbackFuncptr()))
 
+	case OPT_SEEKFUNCTION:
+		if param == nil {
+			curl.seekFunction = nil
+			curl.seekData = nil
+			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
+		}
+		f, ok := param.(func(int64, int, any) int)
+		if !ok {
+			return fmt.Errorf("curl: expected func(int64, int, any) int for SEEKFUNCTION, got %T", param)
+		}
+		curl.seekFunction = &f
+
+		if errCode := curl.setUserdata(OPT_SEEKDATA); errCode != 0 {
+			return newCurlError(errCode)
+		}
+		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetSeekCallbackFuncptr()))
+
 	case OPT_HEADERFUNCTION:
 		if param == nil {
 			curl.headerFunction = nil
 			curl.headerData = nil
+			if len(curl.headerTaps) > 0 {
+				// The trampoline still feeds the header taps.
+				return nil
+			}
 			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
 		}
 		f, ok := param.(func([]byte, any) bool)
@@ -193,11 +318,28 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 		}
 		curl.headerFunction = &f
 
-		if errCode := CurlEasySetoptPointer(p, int(OPT_HEADERDATA), unsafe.Pointer(p)); errCode != 0 {
+		if errCode := curl.setUserdata(OPT_HEADERDATA); errCode != 0 {
 			return newCurlError(errCode)
 		}
 		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetHeaderCallbackFuncptr()))
 
+	case OPT_READDATA:
+		curl.readData = param
+		// Like HEADERDATA, the C-level userdata stays pointed at the handle.
+		return nil
+
+	case OPT_SEEKDATA:
+		curl.seekData = param
+		return nil
+
+	case OPT_PRIVATE:
+		// libcurl's own slot holds the handle for curlFromEasy.
+		curl.private = param
+		return nil
+
+	case OPT_ERRORBUFFER:
+		return fmt.Errorf("curl: ERRORBUFFER is managed by the handle, see Error.Message")
+
 	case OPT_HEADERDATA:
 		curl.headerData = param
 		// We do NOT set the C-level WRITEDATA because HEADERFUNCTION sets it to 'p' (the context)
@@ -215,15 +357,132 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 			return fmt.Errorf("curl: expected func(float64, float64, float64, float64, any) bool for XFERINFOFUNCTION, got %T", param)
 		}
 		curl.progressFunction = &fun
-		if errCode := CurlEasySetoptPointer(p, int(OPT_XFERINFODATA), unsafe.Pointer(p)); errCode != 0 {
+		if errCode := curl.setUserdata(OPT_XFERINFODATA); errCode != 0 {
 			return newCurlError(errCode)
 		}
 		if errCode := CurlEasySetoptLong(p, int(OPT_NOPROGRESS), 0); errCode != 0 {
 			return newCurlError(errCode)
 		}
 		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetProgressCallbackFuncptr()))
+
+	case OPT_HSTSREADFUNCTION:
+		if param == nil {
+			curl.hstsReadFunction = nil
+			curl.hstsReadData = nil
+			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
+		}
+		f, ok := param.(func(any) (HSTSEntry, bool))
+		if !ok {
+			return fmt.Errorf("curl: expected func(any) (HSTSEntry, bool) for HSTSREADFUNCTION, got %T", param)
+		}
+		curl.hstsReadFunction = &f
+		if errCode := curl.setUserdata(OPT_HSTSREADDATA); errCode != 0 {
+			return newCurlError(errCode)
+		}
+		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetHSTSReadCallbackFuncptr()))
+
+	case OPT_HSTSWRITEFUNCTION:
+		if param == nil {
+			curl.hstsWriteFunction = nil
+			curl.hstsWriteData = nil
+			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
+		}
+		f, ok := param.(func(HSTSEntry, int, int, any) bool)
+		if !ok {
+			return fmt.Errorf("curl: expected func(HSTSEntry, int, int, any) bool for HSTSWRITEFUNCTION, got %T", param)
+		}
+		curl.hstsWriteFunction = &f
+		if errCode := curl.setUserdata(OPT_HSTSWRITEDATA); errCode != 0 {
+			return newCurlError(errCode)
+		}
+		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetHSTSWriteCallbackFuncptr()))
+
+	case OPT_DEBUGFUNCTION:
+		if param == nil {
+			curl.debugFunction = nil
+			curl.debugData = nil
+			curl.verboseDebug = false
+			if curl.verbose {
+				// Verbose output goes on to the logger, not to stderr.
+				return curl.Setopt(OPT_VERBOSE, true)
+			}
+			// Undo the verbose mode forced below, or libcurl goes on writing
+			// it to stderr.
+			if errCode := CurlEasySetoptLong(p, int(OPT_VERBOSE), 0); errCode != 0 {
+				return newCurlError(errCode)
+			}
+			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
+		}
+		f, ok := param.(func(Info, []byte, any))
+		if !ok {
+			return fmt.Errorf("curl: expected func(Info, []byte, any) for DEBUGFUNCTION, got %T", param)
+		}
+		curl.debugFunction = &f
+		curl.verboseDebug = false
+		if errCode := curl.setUserdata(OPT_DEBUGDATA); errCode != 0 {
+			return newCurlError(errCode)
+		}
+		// libcurl only calls the debug function in verbose mode.
+		if errCode := CurlEasySetoptLong(p, int(OPT_VERBOSE), 1); errCode != 0 {
+			return newCurlError(errCode)
+		}
+		return newCurlError(CurlEasySetoptFunction(p, int(opt), GetDebugCallbackFuncptr()))
+
+	case OPT_DEBUGDATA:
+		curl.debugData = param
+		return nil
+
+	case OPT_SSL_CTX_FUNCTION:
+		if param == nil {
+			curl.sslCtxFunction = nil
+			curl.sslCtxData = nil
+			if curl.keyLog() != nil || curl.verifyFunction != nil || curl.caPool != nil {
+				// The trampoline still has to install the key log or verify hook.
+				return nil
+			}
+			return newCurlError(CurlEasySetoptFunction(p, int(opt), unsafe.Pointer((*struct{})(nil))))
+		}
+		f, ok := param.(func(*SSLContext, any) error)
+		if !ok {
+			return fmt.Errorf("curl: expected func(*SSLContext, any) error for SSL_CTX_FUNCTION, got %T", param)
+		}
+		curl.sslCtxFunction = &f
+		return curl.installSSLCtxCallback()
+
+	case OPT_SSL_CTX_DATA:
+		curl.sslCtxData = param
+		return nil
+
+	case OPT_VERBOSE:
+		curl.verbose = optionEnabled(param)
+		// Route verbose output through verboseDebugFunction so it ends up in
+		// the configured logger instead of libcurl's stderr.
+		if curl.debugFunction == nil && optionEnabled(param) {
+			if err := curl.Setopt(OPT_DEBUGFUNCTION, curl.verboseDebugFunction); err != nil {
+				return err
+			}
+			curl.verboseDebug = true
+		}
+
+	case OPT_SSL_OPTIONS:
+		// Kept so that SetCASource can change SSLOPT_NATIVE_CA alone.
+		curl.sslOptions = optionLong(param)
+
+	case OPT_PROXY_SSL_OPTIONS:
+		curl.proxySSLOptions = optionLong(param)
+
+	case OPT_HSTSREADDATA:
+		// Like HEADERDATA, the C-level userdata stays pointed at the handle.
+		curl.hstsReadData = param
+		return nil
+
+	case OPT_HSTSWRITEDATA:
+		curl.hstsWriteData = param
+		return nil
 	}
 
+	curl.trackRequest(opt, param)
+
 	if param == nil {
 		return newCurlError(CurlEasySetoptPointer(p, int(opt), nil))
 	}
@@ -245,6 +504,17 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 		}
 		return newCurlError(CurlEasySetoptLong(p, int(opt), val))
 	case string:
+		switch opt {
+		case OPT_URL:
+			curl.url = v
+		case OPT_PROXY:
+			curl.proxy = v
+			if v != "" {
+				if err := curl.captureConnectHeaders(); err != nil {
+					return err
+				}
+			}
+		}
 		var cStr unsafe.Pointer
 		var keepAliveStr []byte
 
@@ -260,6 +530,13 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 		return newCurlError(CurlEasySetoptString(p, int(opt), cStr))
 
 	case []byte:
+		if isBlobOption(opt) {
+			if len(v) == 0 {
+				return newCurlError(CurlEasySetoptPointer(p, int(opt), nil))
+			}
+			// libcurl copies the blob, so v need not outlive the call.
+			return newCurlError(CurlEasySetoptBlob(p, int(opt), v))
+		}
 		var dataPtr unsafe.Pointer
 		if len(v) > 0 {
 			dataPtr = C.CBytes(v)
@@ -332,6 +609,12 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 		}
 		return newCurlError(CurlEasySetoptPointer(p, int(opt), unsafe.Pointer(v.head)))
 
+	case *CURLSH:
+		if v == nil {
+			return newCurlError(CurlEasySetoptPointer(p, int(opt), nil))
+		}
+		return newCurlError(CurlEasySetoptPointer(p, int(opt), v.handle))
+
 	case unsafe.Pointer:
 		return newCurlError(CurlEasySetoptPointer(p, int(opt), v))
 
@@ -340,6 +623,88 @@ func (curl *CURL) Setopt(opt EasyOpt, param any) error {
 	}
 }
 
+// trackRequest remembers the options that decide the HTTP method, the
+// request headers and whether credentials may follow redirects, for helpers
+// that need them before libcurl reports INFO_EFFECTIVE_METHOD.
+func (curl *CURL) trackRequest(opt EasyOpt, param any) {
+	implied := ""
+	switch opt {
+	case OPT_CUSTOMREQUEST:
+		curl.customRequest, _ = param.(string)
+		return
+	case OPT_HTTPHEADER:
+		curl.httpHeader, _ = param.([]string)
+		return
+	case OPT_UNRESTRICTED_AUTH:
+		curl.unrestrictedAuth = optionEnabled(param)
+		return
+	case OPT_HTTPBASEHEADER:
+		lines, _ := param.([]string)
+		curl.baseHeader = ParseHeader(lines)
+		curl.impersonateHeaders = len(lines) > 0
+		return
+	case OPT_POSTFIELDS, OPT_COPYPOSTFIELDS, OPT_MIMEPOST:
+		curl.impliedMethod = "POST"
+		return
+	case OPT_POST:
+		implied = "POST"
+	case OPT_UPLOAD:
+		implied = "PUT"
+	case OPT_NOBODY:
+		implied = "HEAD"
+	case OPT_HTTPGET:
+		implied = "GET"
+	default:
+		return
+	}
+	if optionEnabled(param) {
+		curl.impliedMethod = implied
+	} else if curl.impliedMethod == implied {
+		curl.impliedMethod = ""
+	}
+}
+
+// requestMethod returns the HTTP method implied by the options set so far.
+func (curl *CURL) requestMethod() string {
+	if curl.customRequest != "" {
+		return curl.customRequest
+	}
+	if curl.impliedMethod != "" {
+		return curl.impliedMethod
+	}
+	return "GET"
+}
+
+func optionEnabled(param any) bool {
+	switch v := param.(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	}
+	return false
+}
+
+func optionLong(param any) int64 {
+	switch v := param.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
+
 func isOffTOption(opt EasyOpt) bool {
 	switch opt {
 	case OPT_INFILESIZE_LARGE, OPT_RESUME_FROM_LARGE, OPT_MAXFILESIZE_LARGE, OPT_POSTFIELDSIZE_LARGE, OPT_MAX_SEND_SPEED_LARGE, OPT_MAX_RECV_SPEED_LARGE, OPT_TIMEVALUE_LARGE:
@@ -348,6 +713,16 @@ func isOffTOption(opt EasyOpt) bool {
 	return false
 }
 
+// isBlobOption reports whether opt takes a struct curl_blob.
+func isBlobOption(opt EasyOpt) bool {
+	switch opt {
+	case OPT_CAINFO_BLOB, OPT_ISSUERCERT_BLOB, OPT_SSLCERT_BLOB, OPT_SSLKEY_BLOB,
+		OPT_PROXY_CAINFO_BLOB, OPT_PROXY_ISSUERCERT_BLOB, OPT_PROXY_SSLCERT_BLOB, OPT_PROXY_SSLKEY_BLOB:
+		return true
+	}
+	return false
+}
+
 // curl_easy_send - sends raw data over an "easy" connection
 func (curl *CURL) Send(buffer []byte) (int, error) {
 	p := curl.handle
@@ -399,15 +774,257 @@ func (curl *CURL) Perform() error {
 	if p == nil {
 		return fmt.Errorf("curl: easy handle is nil")
 	}
-	err := newCurlError(CurlEasyPerform(p))
+	for {
+		err := curl.perform()
+		if err != nil || curl.redirect == nil {
+			return err
+		}
+		if follow, err := curl.followRedirect(); !follow {
+			return err
+		}
+	}
+}
+
+// perform runs a single transfer, without following redirects in Go.
+func (curl *CURL) perform() error {
+	p := curl.handle
+	if err := curl.transferStart(); err != nil {
+		return err
+	}
+	served, err := curl.transferServe()
+	if !served {
+		err = curl.newError(CurlEasyPerform(p))
+	}
+	err = curl.transferDone(err)
 
 	runtime.KeepAlive(curl.headerData)
 	runtime.KeepAlive(curl.writeData)
 	runtime.KeepAlive(curl.readData)
 	runtime.KeepAlive(curl.progressData)
+	runtime.KeepAlive(curl.hstsReadData)
+	runtime.KeepAlive(curl.hstsWriteData)
+	runtime.KeepAlive(curl.debugData)
+	runtime.KeepAlive(curl.sslCtxData)
 	return err
 }
 
+// transferHook observes every transfer of a handle, whether it runs through
+// Perform or a multi handle. start may veto the transfer; serve may complete
+// it without libcurl; done may replace its result. When start vetoes the
+// transfer, done still runs for the hooks before it. detach runs when
+// Cleanup or Reset drops the hook, so that helpers can forget the handle.
+type transferHook struct {
+	start  func(*CURL) error
+	serve  func(*CURL) (bool, error)
+	done   func(*CURL, error) error
+	detach func(*CURL)
+}
+
+func (curl *CURL) addTransferHook(h *transferHook) {
+	curl.hooks = append(curl.hooks, h)
+}
+
+// dropTransferHooks removes all transfer hooks and runs their detach.
+func (curl *CURL) dropTransferHooks() {
+	hooks := curl.hooks
+	curl.hooks = nil
+	for _, h := range hooks {
+		if h.detach != nil {
+			h.detach(curl)
+		}
+	}
+}
+
+func (curl *CURL) removeTransferHook(h *transferHook) {
+	for i, v := range curl.hooks {
+		if v == h {
+			curl.hooks = append(curl.hooks[:i:i], curl.hooks[i+1:]...)
+			return
+		}
+	}
+}
+
+func (curl *CURL) transferStart() error {
+	curl.clearErrorBuffer()
+	if h := curl.connectHeaders; h != nil {
+		h.lines, h.done = nil, false
+	}
+	for i, h := range curl.hooks {
+		if h.start == nil {
+			continue
+		}
+		if err := h.start(curl); err != nil {
+			// The hooks before it may hold something for the transfer, so
+			// they see it end with the error.
+			for j := i - 1; j >= 0; j-- {
+				if h := curl.hooks[j]; h.done != nil {
+					err = h.done(curl, err)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (curl *CURL) transferServe() (bool, error) {
+	curl.servedInfo = nil
+	for _, h := range curl.hooks {
+		if h.serve == nil {
+			continue
+		}
+		if served, err := h.serve(curl); served {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+func (curl *CURL) transferDone(err error) error {
+	err = curl.proxyError(err)
+	for i := len(curl.hooks) - 1; i >= 0; i-- {
+		if h := curl.hooks[i]; h.done != nil {
+			err = h.done(curl, err)
+		}
+	}
+	return err
+}
+
+// addHeaderTap makes tap see every header line of every transfer, in
+// addition to and before any HEADERFUNCTION.
+func (curl *CURL) addHeaderTap(tap *func([]byte)) error {
+	curl.headerTaps = append(curl.headerTaps, tap)
+	if curl.headerFunction != nil {
+		return nil
+	}
+	p := curl.handle
+	if errCode := curl.setUserdata(OPT_HEADERDATA); errCode != 0 {
+		return newCurlError(errCode)
+	}
+	return newCurlError(CurlEasySetoptFunction(p, int(OPT_HEADERFUNCTION), GetHeaderCallbackFuncptr()))
+}
+
+// addWriteTap makes tap see the body data of every transfer once the
+// handle's write function or writer has taken it.
+func (curl *CURL) addWriteTap(tap *func([]byte)) error {
+	curl.writeTaps = append(curl.writeTaps, tap)
+	return curl.installWriteCallback()
+}
+
+// setUserdata points a callback userdata option, or OPT_PRIVATE, at the
+// handle's cgo.Handle, which the trampolines resolve with curlFromUserdata.
+func (curl *CURL) setUserdata(opt EasyOpt) CurlCode {
+	return CurlEasySetoptUserdata(curl.handle, int(opt), uintptr(curl.self))
+}
+
+// curlFromUserdata returns the handle whose setUserdata value libcurl
+// passed to a callback. The lookup needs no lock and no global table. It
+// returns nil for a handle already deleted by Cleanup, which libcurl may
+// still pass, e.g. from a duplicate whose parent is gone.
+func curlFromUserdata(userdata uintptr) *CURL {
+	v, ok := handleValue(userdata)
+	if !ok {
+		return nil
+	}
+	curl, _ := v.(*CURL)
+	return curl
+}
+
+// handleValue returns the value of the cgo.Handle h, or false if h is zero
+// or deleted. cgo.Handle.Value panics on deleted handles, and handle values
+// are never reused, so recovering is safe.
+func handleValue(h uintptr) (v any, ok bool) {
+	if h == 0 {
+		return nil, false
+	}
+	defer func() {
+		if recover() != nil {
+			v, ok = nil, false
+		}
+	}()
+	return cgo.Handle(h).Value(), true
+}
+
+// curlFromEasy returns the handle of a libcurl easy handle, from its
+// OPT_PRIVATE.
+func curlFromEasy(p unsafe.Pointer) *CURL {
+	var private uintptr
+	if CurlEasyGetinfoString(p, INFO_PRIVATE, unsafe.Pointer(&private)) != E_OK {
+		return nil
+	}
+	return curlFromUserdata(private)
+}
+
+// installWriteCallback points libcurl's write function at the trampoline,
+// which calls onWrite.
+func (curl *CURL) installWriteCallback() error {
+	p := curl.handle
+	if errCode := curl.setUserdata(OPT_WRITEDATA); errCode != 0 {
+		return newCurlError(errCode)
+	}
+	return newCurlError(CurlEasySetoptFunction(p, int(OPT_WRITEFUNCTION), GetWriteCallbackFuncptr()))
+}
+
+// onWrite passes body data to the handle's write callback and then to the
+// write taps. It returns how many bytes were taken, where a short count
+// fails the transfer, or pause to pause it.
+func (curl *CURL) onWrite(buf []byte) (n int, pause bool) {
+	n, pause = curl.passWrite(buf)
+	if !pause {
+		for _, tap := range curl.writeTaps {
+			(*tap)(buf[:n])
+		}
+	}
+	return n, pause
+}
+
+// passWrite passes body data to WRITEFUNCTION, or else straight to the
+// io.Writer set as WRITEDATA, or stdout. While holdResponse is set it only
+// takes the data; the body of a redirect response is held for
+// followRedirect.
+func (curl *CURL) passWrite(buf []byte) (n int, pause bool) {
+	if curl.holdResponse {
+		return len(buf), false
+	}
+	if st := curl.redirect; st != nil && st.holding {
+		st.held = append(st.held, buf...)
+		return len(buf), false
+	}
+	if curl.writeFunction != nil {
+		if (*curl.writeFunction)(buf, curl.writeData) {
+			return len(buf), false
+		}
+		return 0, true
+	}
+	w := curl.writer
+	if w == nil {
+		w = os.Stdout
+	}
+	n, err := w.Write(buf)
+	if err != nil {
+		curl.logAttrs(_ERROR, "curl: failed to write response body", slog.Any("error", err))
+	}
+	return n, false
+}
+
+// onHeader feeds a header line to the taps and HEADERFUNCTION; false
+// pauses the transfer.
+func (curl *CURL) onHeader(buf []byte) bool {
+	for _, tap := range curl.headerTaps {
+		(*tap)(buf)
+	}
+	return curl.passHeader(buf)
+}
+
+// passHeader feeds a header line to HEADERFUNCTION only, unless
+// holdResponse is set.
+func (curl *CURL) passHeader(buf []byte) bool {
+	if curl.holdResponse || curl.headerFunction == nil {
+		return true
+	}
+	return (*curl.headerFunction)(buf, curl.headerData)
+}
+
 // curl_easy_pause - pause and unpause a connection
 func (curl *CURL) Pause(bitmask int) error {
 	p := curl.handle
@@ -423,14 +1040,62 @@ func (curl *CURL) Reset() {
 	if p != nil {
 		CurlEasyReset(p)
 		curl.MallocFreeAfter(0)
+		curl.url = ""
+		curl.customRequest = ""
+		curl.impliedMethod = ""
+		curl.httpHeader = nil
+		curl.unrestrictedAuth = false
+		curl.sslOptions, curl.proxySSLOptions = 0, 0
+		curl.impersonateTarget = ""
+		curl.impersonateHeaders = false
+		curl.baseHeader = nil
+		curl.sentHeader = nil
+		curl.capturingSent = false
+		curl.servedInfo = nil
 		curl.headerFunction = nil
 		curl.writeFunction = nil
+		curl.writer = nil
 		curl.readFunction = nil
+		curl.seekFunction = nil
 		curl.progressFunction = nil
+		curl.hstsReadFunction = nil
+		curl.hstsWriteFunction = nil
+		curl.debugFunction = nil
+		curl.verbose, curl.verboseDebug = false, false
+		curl.sslCtxFunction = nil
+		curl.dropTransferHooks()
+		curl.headerTaps = nil
+		curl.writeTaps = nil
+		curl.holdResponse = false
+		curl.proxy = ""
+		curl.connectHeaders = nil
+		curl.redirect = nil
+		curl.private = nil
+		if errCode := curl.setUserdata(OPT_PRIVATE); errCode != E_OK {
+			curl.logAttrs(_ERROR, "curl: failed to set private handle data", slog.Any("error", newCurlError(errCode)))
+		}
+		if err := curl.setupErrorBuffer(); err != nil {
+			curl.logAttrs(_ERROR, "curl: failed to set error buffer", slog.Any("error", err))
+		}
 		curl.headerData = nil
 		curl.writeData = nil
 		curl.readData = nil
+		curl.seekData = nil
 		curl.progressData = nil
+		curl.hstsReadData = nil
+		curl.hstsWriteData = nil
+		curl.debugData = nil
+		curl.sslCtxData = nil
+		curl.keyLogWriter = nil
+		curl.verifyFunction = nil
+		curl.verifyHook = nil
+		curl.verifyErr = nil
+		curl.caPool = nil
+		if globalKeyLog.Load() != nil {
+			if err := curl.installSSLCtxCallback(); err != nil {
+				curl.logAttrs(_ERROR, "curl: failed to enable TLS key logging", slog.Any("error", err))
+			}
+		}
 	}
 }
 
@@ -508,6 +1173,13 @@ func (curl *CURL) Getinfo(infoConstant Info) (any, error) {
 		return nil, fmt.Errorf("curl: easy handle is nil")
 	}
 
+	if v, ok := curl.servedInfo[infoConstant]; ok {
+		return v, nil
+	}
+	if infoConstant == INFO_PRIVATE {
+		return curl.private, nil
+	}
+
 	typeMask := GetCurlInfoTypeMask()
 	infoType := infoConstant & typeMask
 
@@ -523,12 +1195,19 @@ func (curl *CURL) Getinfo(infoConstant Info) (any, error) {
 		}
 		return goStringSys(cStrPtr), nil
 	case GetCurlInfoLong():
-		var val int32
+		var val C.long
 		errCode := CurlEasyGetinfoLong(p, infoConstant, unsafe.Pointer(&val))
 		if errCode != E_OK {
 			return nil, newCurlError(errCode)
 		}
 		return int64(val), nil
+	case GetCurlInfoOffT():
+		var val int64
+		errCode := CurlEasyGetinfoOffT(p, infoConstant, unsafe.Pointer(&val))
+		if errCode != E_OK {
+			return nil, newCurlError(errCode)
+		}
+		return val, nil
 	case GetCurlInfoDouble():
 		var val float64
 		errCode := CurlEasyGetinfoDouble(p, infoConstant, unsafe.Pointer(&val))
@@ -560,6 +1239,24 @@ func (curl *CURL) Getinfo(infoConstant Info) (any, error) {
 	}
 }
 
+func (curl *CURL) getinfoString(info Info) string {
+	v, _ := curl.Getinfo(info)
+	s, _ := v.(string)
+	return s
+}
+
+func (curl *CURL) getinfoInt(info Info) int64 {
+	v, _ := curl.Getinfo(info)
+	n, _ := v.(int64)
+	return n
+}
+
+func (curl *CURL) getinfoFloat(info Info) float64 {
+	v, _ := curl.Getinfo(info)
+	f, _ := v.(float64)
+	return f
+}
+
 func PrintCurlVersionInfo(infoPtr unsafe.Pointer) {
 	if infoPtr == nil {
 		fmt.Println("CurlVersionInfoData is nil")
@@ -614,7 +1311,13 @@ func (curl *CURL) Impersonate(target string, defaultHeaders bool) error {
 	if defaultHeaders {
 		cDefaultHeaders = 1
 	}
-	return newCurlError(CurlEasyImpersonate(p, cTarget, cDefaultHeaders))
+	if err := newCurlError(CurlEasyImpersonate(p, cTarget, cDefaultHeaders)); err != nil {
+		return err
+	}
+	curl.impersonateTarget = target
+	curl.impersonateHeaders = defaultHeaders
+	curl.baseHeader = nil
+	return nil
 }
 
 func (curl *CURL) GetHandle() unsafe.Pointer {
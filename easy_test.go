@@ -1,9 +1,12 @@
 package curl
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -75,3 +78,195 @@ func TestConcurrentInitAndCleanup(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestDebugFunction(t *testing.T) {
+	ts := setupTestServer("")
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	var headerOut []byte
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_DEBUGFUNCTION, func(infoType Info, data []byte, userdata any) {
+		if infoType == INFO_HEADER_OUT {
+			headerOut = append(headerOut, data...)
+		}
+	})
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(headerOut), "GET / HTTP/1.1\r\n") {
+		t.Errorf("request header should start with the request line and is %q.", headerOut)
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	ts := setupTestServer("body")
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	var buf bytes.Buffer
+	easy.Setopt(OPT_URL, ts.URL)
+	if err := easy.Setopt(OPT_WRITEDATA, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "body\n" {
+		t.Errorf("WRITEDATA should receive %q and received %q.", "body\n", buf.String())
+	}
+}
+
+// largeBodyServer serves size bytes from memory, so that the benchmarks
+// measure the callback path rather than the server.
+func largeBodyServer(size int) *httptest.Server {
+	body := bytes.Repeat([]byte("x"), size)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+}
+
+func benchmarkDownload(b *testing.B, setup func(*CURL)) {
+	const size = 64 << 20
+	ts := largeBodyServer(size)
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	setup(easy)
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := easy.Perform(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteFunction(b *testing.B) {
+	benchmarkDownload(b, func(easy *CURL) {
+		easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool { return true })
+	})
+}
+
+func BenchmarkWriteData(b *testing.B) {
+	benchmarkDownload(b, func(easy *CURL) {
+		easy.Setopt(OPT_WRITEDATA, io.Discard)
+	})
+}
+
+func TestPrivate(t *testing.T) {
+	easy := EasyInit()
+	defer easy.Cleanup()
+	if err := easy.Setopt(OPT_PRIVATE, "mine"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := easy.Getinfo(INFO_PRIVATE); v != "mine" {
+		t.Errorf("private data should be %q and is %v.", "mine", v)
+	}
+	if c := curlFromEasy(easy.handle); c != easy {
+		t.Error("the libcurl handle should resolve to its CURL.")
+	}
+}
+
+func TestDuphandleCallbacks(t *testing.T) {
+	ts := setupTestServer("dup")
+	defer ts.Close()
+
+	easy := EasyInit()
+	var got string
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		got += string(buf)
+		return true
+	})
+	dup := easy.Duphandle()
+	defer dup.Cleanup()
+	easy.Cleanup()
+
+	if err := dup.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if got != "dup\n" {
+		t.Errorf("duplicate should run the write function of its parent, got %q.", got)
+	}
+}
+
+func TestCurlFromDeletedUserdata(t *testing.T) {
+	easy := EasyInit()
+	userdata := uintptr(easy.self)
+	easy.Cleanup()
+	if c := curlFromUserdata(userdata); c != nil {
+		t.Errorf("a cleaned up handle should resolve to nil, got %p.", c)
+	}
+}
+
+func BenchmarkCallbackDispatch(b *testing.B) {
+	easy := EasyInit()
+	defer easy.Cleanup()
+	userdata := uintptr(easy.self)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if curlFromUserdata(userdata) != easy {
+				b.Fatal("wrong handle")
+			}
+		}
+	})
+}
+
+// BenchmarkMultiConcurrent runs many transfers at once on parallel multi
+// handles, where every chunk of every transfer goes through callback
+// dispatch.
+func BenchmarkMultiConcurrent(b *testing.B) {
+	const size, perMulti = 1 << 20, 16
+	ts := largeBodyServer(size)
+	defer ts.Close()
+
+	b.SetBytes(size * perMulti)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		m := MultiInit()
+		defer m.Cleanup()
+		handles := make([]*CURL, perMulti)
+		for i := range handles {
+			handles[i] = EasyInit()
+			defer handles[i].Cleanup()
+			handles[i].Setopt(OPT_URL, ts.URL)
+			handles[i].Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool { return true })
+		}
+		for pb.Next() {
+			for _, easy := range handles {
+				if err := m.AddHandle(easy); err != nil {
+					b.Fatal(err)
+				}
+			}
+			for {
+				running, err := m.Perform()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if running == 0 {
+					break
+				}
+				m.Wait(nil, 0, 100, nil)
+			}
+			for {
+				msg, _ := m.Info_read()
+				if msg == nil {
+					break
+				}
+				if msg.Err != nil {
+					b.Fatal(msg.Err)
+				}
+			}
+			for _, easy := range handles {
+				m.RemoveHandle(easy)
+			}
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package curl
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// errorBufferSize is CURL_ERROR_SIZE.
+const errorBufferSize = 256
+
+// ErrorPhase is the stage of a transfer in which it failed.
+type ErrorPhase int
+
+const (
+	PhaseUnknown ErrorPhase = iota
+	// PhaseSetup covers invalid options, URLs and protocols.
+	PhaseSetup
+	PhaseResolve
+	PhaseConnect
+	// PhaseTLS covers the handshake with the server or an HTTPS proxy.
+	PhaseTLS
+	// PhaseSend covers sending the request, up to the first response byte.
+	PhaseSend
+	PhaseReceive
+	// PhaseCallback means a Go callback aborted the transfer.
+	PhaseCallback
+)
+
+func (p ErrorPhase) String() string {
+	switch p {
+	case PhaseSetup:
+		return "setup"
+	case PhaseResolve:
+		return "resolve"
+	case PhaseConnect:
+		return "connect"
+	case PhaseTLS:
+		return "tls"
+	case PhaseSend:
+		return "send"
+	case PhaseReceive:
+		return "receive"
+	case PhaseCallback:
+		return "callback"
+	}
+	return "unknown"
+}
+
+// Error is returned by Perform and in CURLMessage.Err when libcurl fails a
+// transfer. It unwraps to the CurlError of Code, so
+// errors.Is(err, CurlError(E_OPERATION_TIMEDOUT)) keeps working.
+type Error struct {
+	Code CurlCode
+	// Message is the detailed text libcurl wrote to its error buffer, or
+	// empty if it wrote none.
+	Message string
+	// OSErrno is INFO_OS_ERRNO, the errno of a failed connect.
+	OSErrno int
+	// URL is the effective URL, INFO_EFFECTIVE_URL.
+	URL   string
+	Phase ErrorPhase
+}
+
+func (e *Error) Error() string {
+	s := CurlError(e.Code).Error()
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	if e.URL != "" {
+		s += fmt.Sprintf(" (%s)", e.URL)
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error {
+	return CurlError(e.Code)
+}
+
+// setupErrorBuffer gives libcurl a buffer for detailed error messages. It
+// must be called again after curl_easy_reset, which keeps the buffer.
+func (curl *CURL) setupErrorBuffer() error {
+	if curl.errorBuffer == nil {
+		if runtime.GOOS == "windows" {
+			// The DLL writes into Go memory, which does not move.
+			curl.errorBufferKeep = make([]byte, errorBufferSize)
+			curl.errorBuffer = unsafe.Pointer(&curl.errorBufferKeep[0])
+		} else {
+			curl.errorBuffer = C.calloc(1, errorBufferSize)
+		}
+	}
+	return newCurlError(CurlEasySetoptPointer(curl.handle, int(OPT_ERRORBUFFER), curl.errorBuffer))
+}
+
+func (curl *CURL) freeErrorBuffer() {
+	if curl.errorBuffer != nil && runtime.GOOS != "windows" {
+		C.free(curl.errorBuffer)
+	}
+	curl.errorBuffer = nil
+	curl.errorBufferKeep = nil
+}
+
+func (curl *CURL) clearErrorBuffer() {
+	if curl.errorBuffer != nil {
+		*(*byte)(curl.errorBuffer) = 0
+	}
+}
+
+// newError wraps the result of a libcurl transfer.
+func (curl *CURL) newError(code CurlCode) error {
+	if code == E_OK {
+		return nil
+	}
+	if curl.handle == nil {
+		return CurlError(code)
+	}
+	e := &Error{
+		Code:    code,
+		OSErrno: int(curl.getinfoInt(INFO_OS_ERRNO)),
+		URL:     curl.getinfoString(INFO_EFFECTIVE_URL),
+		Phase:   curl.errorPhase(code),
+	}
+	if curl.errorBuffer != nil {
+		e.Message = goStringSys(uintptr(curl.errorBuffer))
+	}
+	return e
+}
+
+// errorPhase derives the phase from the code, or for ambiguous codes from
+// how far the transfer got according to its timings.
+func (curl *CURL) errorPhase(code CurlCode) ErrorPhase {
+	switch code {
+	case E_UNSUPPORTED_PROTOCOL, E_FAILED_INIT, E_URL_MALFORMAT, E_NOT_BUILT_IN,
+		E_BAD_FUNCTION_ARGUMENT, E_UNKNOWN_OPTION, E_SETOPT_OPTION_SYNTAX:
+		return PhaseSetup
+	case E_COULDNT_RESOLVE_HOST, E_COULDNT_RESOLVE_PROXY:
+		return PhaseResolve
+	case E_COULDNT_CONNECT, E_PROXY, E_INTERFACE_FAILED:
+		return PhaseConnect
+	case E_SSL_CONNECT_ERROR, E_PEER_FAILED_VERIFICATION, E_SSL_CERTPROBLEM, E_SSL_CIPHER,
+		E_SSL_CACERT_BADFILE, E_SSL_CRL_BADFILE, E_SSL_ISSUER_ERROR, E_SSL_PINNEDPUBKEYNOTMATCH,
+		E_SSL_INVALIDCERTSTATUS, E_SSL_CLIENTCERT, E_SSL_ENGINE_NOTFOUND, E_SSL_ENGINE_SETFAILED,
+		E_SSL_ENGINE_INITFAILED, E_USE_SSL_FAILED, E_ECH_REQUIRED:
+		return PhaseTLS
+	case E_SEND_ERROR, E_SEND_FAIL_REWIND:
+		return PhaseSend
+	case E_RECV_ERROR, E_GOT_NOTHING, E_PARTIAL_FILE, E_BAD_CONTENT_ENCODING, E_HTTP2_STREAM,
+		E_TOO_MANY_REDIRECTS, E_HTTP_RETURNED_ERROR, E_FILESIZE_EXCEEDED, E_WEIRD_SERVER_REPLY,
+		E_TOO_LARGE:
+		return PhaseReceive
+	case E_WRITE_ERROR, E_READ_ERROR, E_ABORTED_BY_CALLBACK:
+		return PhaseCallback
+	}
+	switch {
+	case curl.getinfoFloat(INFO_NAMELOOKUP_TIME) == 0:
+		return PhaseResolve
+	case curl.getinfoFloat(INFO_CONNECT_TIME) == 0:
+		return PhaseConnect
+	case curl.getinfoFloat(INFO_PRETRANSFER_TIME) == 0:
+		// The connection is up but was not ready to send: the TLS or
+		// proxy handshake did not finish.
+		return PhaseTLS
+	case curl.getinfoFloat(INFO_STARTTRANSFER_TIME) == 0:
+		return PhaseSend
+	}
+	return PhaseReceive
+}
@@ -0,0 +1,57 @@
+package curl
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestErrorDetails(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + l.Addr().String() + "/"
+	l.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, url)
+
+	err = easy.Perform()
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("error should be an *Error and is %v.", err)
+	}
+	if !errors.Is(err, CurlError(E_COULDNT_CONNECT)) {
+		t.Errorf("error should match %v and is %v.", CurlError(E_COULDNT_CONNECT), err)
+	}
+	if e.Phase != PhaseConnect {
+		t.Errorf("phase should be %v and is %v.", PhaseConnect, e.Phase)
+	}
+	if e.URL != url {
+		t.Errorf("URL should be %s and is %s.", url, e.URL)
+	}
+	if e.OSErrno == 0 {
+		t.Error("OS errno should be set for a refused connection.")
+	}
+	if !strings.Contains(e.Message, l.Addr().(*net.TCPAddr).IP.String()) {
+		t.Errorf("message should name the host and is %q.", e.Message)
+	}
+}
+
+func TestErrorBufferAfterReset(t *testing.T) {
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Reset()
+	easy.Setopt(OPT_URL, "nope://example.com/")
+
+	var e *Error
+	if err := easy.Perform(); !errors.As(err, &e) || e.Phase != PhaseSetup || e.Message == "" {
+		t.Errorf("unsupported protocol should fail in setup with a message, got %v.", err)
+	}
+	if err := easy.Setopt(OPT_ERRORBUFFER, []byte{}); err == nil {
+		t.Error("setting ERRORBUFFER should fail.")
+	}
+}
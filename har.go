@@ -0,0 +1,439 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is an HTTP Archive 1.2 document, as exported by browser devtools.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	// Error is the error of a failed transfer, whose entry has status 0
+	// and timings for the phases reached before it failed.
+	Error string `json:"_error,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are in milliseconds; -1 marks a phase that did not happen.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder captures a HAR entry for every transfer of the handles it is
+// attached to, including failed ones. Headers are taken from the debug
+// callback as they were sent and received on the wire, timings from
+// Getinfo. When a transfer follows redirects, the entry describes the last
+// request and response.
+type HARRecorder struct {
+	// RecordBodies stores request and response bodies in the entries.
+	RecordBodies bool
+
+	mu        sync.Mutex
+	entries   []HAREntry
+	transfers map[*CURL]*harTransfer
+}
+
+type harTransfer struct {
+	hook    *transferHook
+	started time.Time
+
+	requestLine  string
+	reqHeaders   []HARNameValue
+	reqHeaderLen int64
+	reqBody      bytes.Buffer
+	reqBodyLen   int64
+
+	statusLine    string
+	respHeaders   []HARNameValue
+	respHeaderLen int64
+	respBody      bytes.Buffer
+	respBodyLen   int64
+}
+
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{transfers: make(map[*CURL]*harTransfer)}
+}
+
+// Attach starts recording the transfers of curl. The recorder chains to any
+// debug function already set on the handle, so set it up after the handle's
+// own callbacks.
+func (r *HARRecorder) Attach(curl *CURL) error {
+	t := &harTransfer{}
+	t.hook = &transferHook{
+		start: func(*CURL) error {
+			r.mu.Lock()
+			t.reset()
+			t.started = time.Now()
+			r.mu.Unlock()
+			return nil
+		},
+		done: func(c *CURL, err error) error {
+			r.finish(c, t, err)
+			return err
+		},
+		detach: func(c *CURL) {
+			r.mu.Lock()
+			if r.transfers[c] == t {
+				delete(r.transfers, c)
+			}
+			r.mu.Unlock()
+		},
+	}
+
+	prev := curl.debugFunction
+	err := curl.Setopt(OPT_DEBUGFUNCTION, func(infoType Info, data []byte, userdata any) {
+		r.mu.Lock()
+		t.capture(infoType, data, r.RecordBodies)
+		r.mu.Unlock()
+		if prev != nil {
+			(*prev)(infoType, data, userdata)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.transfers[curl] = t
+	r.mu.Unlock()
+	curl.addTransferHook(t.hook)
+	return nil
+}
+
+// AttachMulti attaches the recorder to every handle subsequently added to m.
+func (r *HARRecorder) AttachMulti(m *CURLM) {
+	m.addHooks = append(m.addHooks, func(easy *CURL) error {
+		r.mu.Lock()
+		_, ok := r.transfers[easy]
+		r.mu.Unlock()
+		if ok {
+			return nil
+		}
+		return r.Attach(easy)
+	})
+}
+
+// Detach stops recording curl's transfers. The handle's debug function is
+// left in place.
+func (r *HARRecorder) Detach(curl *CURL) {
+	r.mu.Lock()
+	t, ok := r.transfers[curl]
+	delete(r.transfers, curl)
+	r.mu.Unlock()
+	if ok {
+		curl.removeTransferHook(t.hook)
+	}
+}
+
+// Entries returns a copy of the entries recorded so far.
+func (r *HARRecorder) Entries() []HAREntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]HAREntry(nil), r.entries...)
+}
+
+// HAR returns the recorded entries as a HAR document.
+func (r *HARRecorder) HAR() *HAR {
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "go-curl-impersonate", Version: Version()},
+		Entries: r.Entries(),
+	}}
+}
+
+// WriteTo writes the HAR document as JSON to w.
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (t *harTransfer) reset() {
+	t.requestLine = ""
+	t.reqHeaders = nil
+	t.reqHeaderLen = 0
+	t.reqBody.Reset()
+	t.reqBodyLen = 0
+	t.resetResponse()
+}
+
+func (t *harTransfer) resetResponse() {
+	t.statusLine = ""
+	t.respHeaders = nil
+	t.respHeaderLen = 0
+	t.respBody.Reset()
+	t.respBodyLen = 0
+}
+
+func (t *harTransfer) capture(infoType Info, data []byte, bodies bool) {
+	switch infoType {
+	case INFO_HEADER_OUT:
+		// libcurl hands over the complete request header block at once.
+		t.reset()
+		t.reqHeaderLen = int64(len(data))
+		for i, line := range strings.Split(string(data), "\r\n") {
+			if i == 0 {
+				t.requestLine = line
+				continue
+			}
+			if h, ok := parseHeaderLine(line); ok {
+				t.reqHeaders = append(t.reqHeaders, h)
+			}
+		}
+	case INFO_HEADER_IN:
+		line := strings.TrimRight(string(data), "\r\n")
+		if strings.HasPrefix(line, "HTTP/") {
+			t.resetResponse()
+			t.statusLine = line
+		} else if h, ok := parseHeaderLine(line); ok {
+			t.respHeaders = append(t.respHeaders, h)
+		}
+		t.respHeaderLen += int64(len(data))
+	case INFO_DATA_OUT:
+		t.reqBodyLen += int64(len(data))
+		if bodies {
+			t.reqBody.Write(data)
+		}
+	case INFO_DATA_IN:
+		t.respBodyLen += int64(len(data))
+		if bodies {
+			t.respBody.Write(data)
+		}
+	}
+}
+
+func parseHeaderLine(line string) (HARNameValue, bool) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return HARNameValue{}, false
+	}
+	return HARNameValue{Name: name, Value: strings.TrimSpace(value)}, true
+}
+
+func headerValue(headers []HARNameValue, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// harMillis converts a Getinfo duration in seconds to HAR milliseconds.
+func harMillis(seconds float64) float64 {
+	if seconds < 0 {
+		return -1
+	}
+	return float64(time.Duration(seconds*float64(time.Second)).Microseconds()) / 1000
+}
+
+func (r *HARRecorder) finish(curl *CURL, t *harTransfer, err error) {
+	nameLookup := curl.getinfoFloat(INFO_NAMELOOKUP_TIME)
+	connect := curl.getinfoFloat(INFO_CONNECT_TIME)
+	appConnect := curl.getinfoFloat(INFO_APPCONNECT_TIME)
+	preTransfer := curl.getinfoFloat(INFO_PRETRANSFER_TIME)
+	startTransfer := curl.getinfoFloat(INFO_STARTTRANSFER_TIME)
+	total := curl.getinfoFloat(INFO_TOTAL_TIME)
+
+	timings := HARTimings{
+		Blocked: -1,
+		DNS:     harMillis(nameLookup),
+		Connect: harMillis(connect - nameLookup),
+		SSL:     -1,
+		Send:    harMillis(preTransfer - max(connect, appConnect)),
+		Wait:    harMillis(startTransfer - preTransfer),
+		Receive: harMillis(total - startTransfer),
+	}
+	if appConnect > 0 {
+		// HAR counts the TLS handshake in both connect and ssl.
+		timings.Connect = harMillis(appConnect - nameLookup)
+		timings.SSL = harMillis(appConnect - connect)
+	}
+	if err != nil {
+		// A failed transfer leaves the times of the phases it did not
+		// reach at zero.
+		for _, phase := range []struct {
+			at     float64
+			timing *float64
+		}{
+			{nameLookup, &timings.DNS},
+			{connect, &timings.Connect},
+			{preTransfer, &timings.Send},
+			{startTransfer, &timings.Wait},
+			{startTransfer, &timings.Receive},
+		} {
+			if phase.at <= 0 {
+				*phase.timing = -1
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	effectiveURL := curl.getinfoString(INFO_EFFECTIVE_URL)
+	if effectiveURL == "" {
+		effectiveURL = curl.url
+	}
+	// A transfer that failed before sending has no request line.
+	method, reqVersion := curl.requestMethod(), ""
+	if parts := strings.Fields(t.requestLine); len(parts) == 3 {
+		method, reqVersion = parts[0], parts[2]
+	}
+	req := HARRequest{
+		Method:      method,
+		URL:         effectiveURL,
+		HTTPVersion: reqVersion,
+		Cookies:     []HARCookie{},
+		Headers:     append([]HARNameValue{}, t.reqHeaders...),
+		QueryString: []HARNameValue{},
+		HeadersSize: t.reqHeaderLen,
+		BodySize:    t.reqBodyLen,
+	}
+	if u, err := url.Parse(effectiveURL); err == nil {
+		for k, vs := range u.Query() {
+			for _, v := range vs {
+				req.QueryString = append(req.QueryString, HARNameValue{Name: k, Value: v})
+			}
+		}
+	}
+	if t.reqBodyLen > 0 {
+		req.PostData = &HARPostData{MimeType: headerValue(t.reqHeaders, "Content-Type")}
+		if r.RecordBodies {
+			req.PostData.Text = t.reqBody.String()
+		}
+	}
+
+	resp := HARResponse{
+		Status:      int(curl.getinfoInt(INFO_RESPONSE_CODE)),
+		Cookies:     []HARCookie{},
+		Headers:     append([]HARNameValue{}, t.respHeaders...),
+		RedirectURL: curl.getinfoString(INFO_REDIRECT_URL),
+		HeadersSize: t.respHeaderLen,
+		BodySize:    t.respBodyLen,
+		Content: HARContent{
+			Size:     t.respBodyLen,
+			MimeType: headerValue(t.respHeaders, "Content-Type"),
+		},
+	}
+	if parts := strings.SplitN(t.statusLine, " ", 3); len(parts) >= 2 {
+		resp.HTTPVersion = parts[0]
+		if len(parts) == 3 {
+			resp.StatusText = strings.TrimSpace(parts[2])
+		}
+		if resp.Status == 0 {
+			resp.Status, _ = strconv.Atoi(parts[1])
+		}
+	}
+	if err != nil {
+		resp.Status, resp.StatusText = 0, ""
+	}
+	if r.RecordBodies && t.respBody.Len() > 0 {
+		if utf8.Valid(t.respBody.Bytes()) {
+			resp.Content.Text = t.respBody.String()
+		} else {
+			resp.Content.Text = base64.StdEncoding.EncodeToString(t.respBody.Bytes())
+			resp.Content.Encoding = "base64"
+		}
+	}
+
+	entry := HAREntry{
+		StartedDateTime: t.started.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            harMillis(total),
+		Request:         req,
+		Response:        resp,
+		Timings:         timings,
+		ServerIPAddress: curl.getinfoString(INFO_PRIMARY_IP),
+	}
+	if port := curl.getinfoInt(INFO_PRIMARY_PORT); port > 0 {
+		entry.Connection = strconv.FormatInt(port, 10)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	r.entries = append(r.entries, entry)
+}
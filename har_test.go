@@ -0,0 +1,94 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	serverContent := "A random string"
+	ts := setupTestServer(serverContent)
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	rec := NewHARRecorder()
+	rec.RecordBodies = true
+	easy.Setopt(OPT_URL, ts.URL+"/path?q=1")
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	if err := rec.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("recorder should have 1 entry and has %d.", len(entries))
+	}
+	e := entries[0]
+	if e.Request.Method != "GET" || e.Request.HTTPVersion != "HTTP/1.1" {
+		t.Errorf("unexpected request line: %s %s", e.Request.Method, e.Request.HTTPVersion)
+	}
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0] != (HARNameValue{Name: "q", Value: "1"}) {
+		t.Errorf("unexpected query string: %v", e.Request.QueryString)
+	}
+	if e.Response.Status != 200 || e.Response.StatusText != "OK" {
+		t.Errorf("unexpected status: %d %q", e.Response.Status, e.Response.StatusText)
+	}
+	if e.Response.Content.Text != serverContent+"\n" {
+		t.Errorf("response body should be %q and is %q.", serverContent+"\n", e.Response.Content.Text)
+	}
+	if e.ServerIPAddress != "127.0.0.1" {
+		t.Errorf("server IP should be 127.0.0.1 and is %q.", e.ServerIPAddress)
+	}
+
+	var buf bytes.Buffer
+	if _, err := rec.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc HAR
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 1 {
+		t.Errorf("unexpected HAR document: %+v", doc.Log)
+	}
+}
+
+func TestHARRecorderFailedTransfer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	rec := NewHARRecorder()
+	easy.Setopt(OPT_URL, "http://"+addr+"/")
+	if err := rec.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err == nil {
+		t.Fatal("transfer to a closed port should fail.")
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("recorder should have 1 entry for the failed transfer and has %d.", len(entries))
+	}
+	e := entries[0]
+	if e.Response.Status != 0 || e.Error == "" {
+		t.Errorf("failed entry should have status 0 and an error, has %d and %q.", e.Response.Status, e.Error)
+	}
+	if e.Request.Method != "GET" || e.Timings.Wait != -1 || e.Timings.Receive != -1 {
+		t.Errorf("unexpected failed entry: %+v", e)
+	}
+}
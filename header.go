@@ -0,0 +1,153 @@
+package curl
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderField is a header with its name spelled as it is sent or received.
+type HeaderField struct {
+	Name, Value string
+}
+
+// Header is an ordered list of header fields. Unlike http.Header it keeps
+// the order and spelling of names, which impersonation depends on. Name
+// lookups are case-insensitive.
+type Header []HeaderField
+
+// ParseHeader parses header lines as passed to the header callback,
+// skipping status and blank lines. A "Name;" line as used in OPT_HTTPHEADER
+// gives a field with an empty value.
+func ParseHeader(lines []string) Header {
+	var h Header
+	for _, line := range lines {
+		if strings.HasPrefix(line, "HTTP/") {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			h = append(h, HeaderField{strings.TrimSpace(name), strings.TrimSpace(value)})
+		} else if name, ok := strings.CutSuffix(strings.TrimSpace(line), ";"); ok && name != "" {
+			h = append(h, HeaderField{name, ""})
+		}
+	}
+	return h
+}
+
+// Get returns the value of the first field named name, or "".
+func (h Header) Get(name string) string {
+	if i := h.index(name); i >= 0 {
+		return h[i].Value
+	}
+	return ""
+}
+
+// Values returns the values of all fields named name.
+func (h Header) Values(name string) []string {
+	var values []string
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// Add appends a field.
+func (h *Header) Add(name, value string) {
+	*h = append(*h, HeaderField{name, value})
+}
+
+// Set replaces the first field named name in place, with the new spelling,
+// and removes any others; without one it appends the field.
+func (h *Header) Set(name, value string) {
+	i := h.index(name)
+	if i < 0 {
+		h.Add(name, value)
+		return
+	}
+	(*h)[i] = HeaderField{name, value}
+	rest := (*h)[i+1:]
+	rest.Del(name)
+	*h = append((*h)[:i+1], rest...)
+}
+
+// InsertBefore inserts a field before the first field named anchor, or
+// appends it if there is none.
+func (h *Header) InsertBefore(anchor, name, value string) {
+	h.insert(h.index(anchor), name, value)
+}
+
+// InsertAfter inserts a field after the last field named anchor, or appends
+// it if there is none.
+func (h *Header) InsertAfter(anchor, name, value string) {
+	i := -1
+	for j, f := range *h {
+		if strings.EqualFold(f.Name, anchor) {
+			i = j + 1
+		}
+	}
+	h.insert(i, name, value)
+}
+
+func (h *Header) insert(i int, name, value string) {
+	if i < 0 {
+		h.Add(name, value)
+		return
+	}
+	*h = append(*h, HeaderField{})
+	copy((*h)[i+1:], (*h)[i:])
+	(*h)[i] = HeaderField{name, value}
+}
+
+// Names returns the field names in order.
+func (h Header) Names() []string {
+	names := make([]string, len(h))
+	for i, f := range h {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Del removes all fields named name.
+func (h *Header) Del(name string) {
+	kept := (*h)[:0]
+	for _, f := range *h {
+		if !strings.EqualFold(f.Name, name) {
+			kept = append(kept, f)
+		}
+	}
+	*h = kept
+}
+
+// Lines returns the fields as "Name: value" lines for OPT_HTTPHEADER. A
+// field with an empty value becomes "Name;", as libcurl drops "Name:" lines
+// instead of sending them.
+func (h Header) Lines() []string {
+	lines := make([]string, len(h))
+	for i, f := range h {
+		if f.Value == "" {
+			lines[i] = f.Name + ";"
+		} else {
+			lines[i] = f.Name + ": " + f.Value
+		}
+	}
+	return lines
+}
+
+// HTTPHeader converts h to an http.Header, losing order and spelling.
+func (h Header) HTTPHeader() http.Header {
+	hh := make(http.Header, len(h))
+	for _, f := range h {
+		hh.Add(f.Name, f.Value)
+	}
+	return hh
+}
+
+func (h Header) index(name string) int {
+	for i, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,180 @@
+package curl
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// defaultHeaderCache holds the default headers of impersonation targets
+// learned by probeDefaultHeader.
+var defaultHeaderCache sync.Map
+
+// DefaultHeader returns the default headers the handle sends, in order:
+// those set by Impersonate or OPT_HTTPBASEHEADER. It is empty if
+// impersonation was set up without default headers.
+//
+// libcurl does not expose the defaults of a target, so the first call for a
+// target learns them by sending one request to a listener on the loopback
+// interface.
+func (curl *CURL) DefaultHeader() (Header, error) {
+	if !curl.impersonateHeaders {
+		return nil, nil
+	}
+	if curl.baseHeader != nil {
+		return append(Header(nil), curl.baseHeader...), nil
+	}
+	h, err := probeDefaultHeader(curl.impersonateTarget)
+	if err != nil {
+		return nil, err
+	}
+	return append(Header(nil), h...), nil
+}
+
+// SetDefaultHeader replaces the default headers of the handle. An empty
+// header removes them.
+func (curl *CURL) SetDefaultHeader(h Header) error {
+	if len(h) == 0 {
+		return curl.Setopt(OPT_HTTPBASEHEADER, nil)
+	}
+	return curl.Setopt(OPT_HTTPBASEHEADER, h.Lines())
+}
+
+// EditDefaultHeader passes the default headers to edit and sets the result
+// as the new defaults. Headers set with OPT_HTTPHEADER replace defaults of
+// the same name in place and the others are sent after the defaults, so
+// this is the way to control the complete order:
+//
+//	easy.Impersonate("chrome136", true)
+//	easy.EditDefaultHeader(func(h *curl.Header) {
+//		h.InsertAfter("Accept-Language", "Cookie", "session=1")
+//	})
+func (curl *CURL) EditDefaultHeader(edit func(h *Header)) error {
+	h, err := curl.DefaultHeader()
+	if err != nil {
+		return err
+	}
+	edit(&h)
+	return curl.SetDefaultHeader(h)
+}
+
+// CaptureSentHeader makes the handle record the request headers of every
+// transfer as they go out, see SentHeader. It chains to the debug function
+// already set on the handle, so set it up after the handle's own callbacks.
+func (curl *CURL) CaptureSentHeader() error {
+	if curl.capturingSent {
+		return nil
+	}
+	prev := curl.debugFunction
+	err := curl.Setopt(OPT_DEBUGFUNCTION, func(infoType Info, data []byte, userdata any) {
+		if infoType == INFO_HEADER_OUT {
+			curl.sentHeader = parseRequestHeader(string(data))
+		}
+		if prev != nil {
+			(*prev)(infoType, data, userdata)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	curl.capturingSent = true
+	curl.addTransferHook(&transferHook{
+		start: func(curl *CURL) error {
+			curl.sentHeader = nil
+			return nil
+		},
+	})
+	return nil
+}
+
+// SentHeader returns the headers of the last request sent, in on-wire
+// order, including those libcurl generates such as Host. When redirects are
+// followed it describes the last hop. It needs CaptureSentHeader.
+func (curl *CURL) SentHeader() Header {
+	return append(Header(nil), curl.sentHeader...)
+}
+
+// parseRequestHeader parses a request header block as passed to the debug
+// function, skipping the request line.
+func parseRequestHeader(block string) Header {
+	lines := strings.Split(block, "\r\n")
+	var h Header
+	for _, line := range lines[1:] {
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			h = append(h, HeaderField{name, strings.TrimSpace(value)})
+		}
+	}
+	return h
+}
+
+// probeDefaultHeader learns the default headers of an impersonation target
+// from a plain HTTP/1.1 request to a loopback listener. The Host header is
+// dropped since libcurl generates it per request.
+func probeDefaultHeader(target string) (Header, error) {
+	if h, ok := defaultHeaderCache.Load(target); ok {
+		return h.(Header), nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("curl: failed to listen for header probe: %w", err)
+	}
+	defer ln.Close()
+	go serveHeaderProbe(ln)
+
+	probe, err := initEasy()
+	if err != nil {
+		return nil, err
+	}
+	defer probe.Cleanup()
+	if err := probe.Impersonate(target, true); err != nil {
+		return nil, err
+	}
+	opts := []struct {
+		opt   EasyOpt
+		param any
+	}{
+		// HTTP/2 over plain HTTP would add the h2c upgrade headers.
+		{OPT_HTTP_VERSION, HTTP_VERSION_1_1},
+		{OPT_NOPROXY, "*"},
+		{OPT_TIMEOUT_MS, 5000},
+		{OPT_URL, "http://" + ln.Addr().String() + "/"},
+		{OPT_WRITEFUNCTION, func([]byte, any) bool { return true }},
+	}
+	for _, o := range opts {
+		if err := probe.Setopt(o.opt, o.param); err != nil {
+			return nil, err
+		}
+	}
+	if err := probe.CaptureSentHeader(); err != nil {
+		return nil, err
+	}
+	if err := probe.Perform(); err != nil {
+		return nil, fmt.Errorf("curl: header probe for %s failed: %w", target, err)
+	}
+	h := probe.SentHeader()
+	h.Del("Host")
+	defaultHeaderCache.Store(target, h)
+	return h, nil
+}
+
+// serveHeaderProbe answers one request on ln with an empty response.
+func serveHeaderProbe(ln net.Listener) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil || line == "\r\n" {
+			break
+		}
+	}
+	conn.Write([]byte("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n"))
+}
@@ -0,0 +1,87 @@
+package curl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeaderOrder(t *testing.T) {
+	var h Header
+	h.Add("A", "1")
+	h.Add("B", "2")
+	h.Add("a", "3")
+	h.Add("C", "4")
+	h.Set("a", "5")
+	h.Del("b")
+	want := []string{"a: 5", "C: 4"}
+	if got := h.Lines(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines should be %q and are %q.", want, got)
+	}
+}
+
+func TestHeaderEmptyValue(t *testing.T) {
+	h := Header{{"X-Empty", ""}, {"A", "1"}}
+	want := []string{"X-Empty;", "A: 1"}
+	lines := h.Lines()
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lines should be %q and are %q.", want, lines)
+	}
+	if got := ParseHeader(lines); len(got) != 2 || got[0] != h[0] || got[1] != h[1] {
+		t.Errorf("parsed lines should give back %v, got %v.", h, got)
+	}
+}
+
+func TestHeaderInsert(t *testing.T) {
+	h := Header{{"A", "1"}, {"B", "2"}, {"b", "3"}}
+	h.InsertBefore("a", "X", "x")
+	h.InsertAfter("B", "Y", "y")
+	h.InsertAfter("missing", "Z", "z")
+	want := []string{"X", "A", "B", "b", "Y", "Z"}
+	if got := h.Names(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("names should be %q and are %q.", want, got)
+	}
+}
+
+func TestDefaultHeaderOrder(t *testing.T) {
+	ts := echoServer()
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	if err := easy.Impersonate("chrome136", true); err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := easy.DefaultHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults.Get("User-Agent") == "" || defaults.Get("Accept-Language") == "" {
+		t.Fatalf("defaults should include User-Agent and Accept-Language, got %q.", defaults.Names())
+	}
+	err = easy.EditDefaultHeader(func(h *Header) {
+		h.InsertAfter("Accept-Language", "Cookie", "a=1")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.CaptureSentHeader(); err != nil {
+		t.Fatal(err)
+	}
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := easy.SentHeader()
+	i := sent.index("Accept-Language")
+	if i < 0 || i+1 >= len(sent) || sent[i+1].Name != "Cookie" {
+		t.Errorf("Cookie should follow Accept-Language on the wire, sent %q.", sent.Names())
+	}
+	sent.Del("Host")
+	want := append(Header(nil), defaults...)
+	want.InsertAfter("Accept-Language", "Cookie", "a=1")
+	if strings.Join(sent.Names(), ",") != strings.Join(want.Names(), ",") {
+		t.Errorf("sent order should be %q and is %q.", want.Names(), sent.Names())
+	}
+}
@@ -0,0 +1,121 @@
+package curl
+
+import (
+	"time"
+)
+
+// hstsExpireLayout is the "YYYYMMDD HH:MM:SS" format libcurl uses for
+// curl_hstsentry.expire.
+const hstsExpireLayout = "20060102 15:04:05"
+
+// HSTSEntry is a single HSTS cache entry as exchanged with libcurl through
+// OPT_HSTSREADFUNCTION and OPT_HSTSWRITEFUNCTION.
+type HSTSEntry struct {
+	Host              string
+	IncludeSubDomains bool
+	// Expire is the time the entry stops being valid. The zero value means
+	// the entry never expires.
+	Expire time.Time
+}
+
+func (e HSTSEntry) expireString() string {
+	if e.Expire.IsZero() {
+		return ""
+	}
+	return e.Expire.UTC().Format(hstsExpireLayout)
+}
+
+func parseHSTSExpire(s string) time.Time {
+	t, err := time.Parse(hstsExpireLayout, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// HSTSStore persists HSTS entries outside of libcurl, e.g. in a database
+// shared between processes.
+type HSTSStore interface {
+	// Load returns the entries libcurl should start the transfer with.
+	Load() []HSTSEntry
+	// Save receives the complete HSTS cache when the handle is cleaned up.
+	Save([]HSTSEntry)
+}
+
+// SetHSTSStore enables HSTS on the handle and wires OPT_HSTSREADFUNCTION and
+// OPT_HSTSWRITEFUNCTION to store. Passing nil removes both callbacks.
+func (curl *CURL) SetHSTSStore(store HSTSStore) error {
+	if store == nil {
+		if err := curl.Setopt(OPT_HSTSREADFUNCTION, nil); err != nil {
+			return err
+		}
+		return curl.Setopt(OPT_HSTSWRITEFUNCTION, nil)
+	}
+
+	var pending []HSTSEntry
+	loaded := false
+	read := func(any) (HSTSEntry, bool) {
+		if !loaded {
+			pending = store.Load()
+			loaded = true
+		}
+		if len(pending) == 0 {
+			// libcurl may ask again on the next transfer; start over then.
+			loaded = false
+			return HSTSEntry{}, false
+		}
+		e := pending[0]
+		pending = pending[1:]
+		return e, true
+	}
+
+	var saved []HSTSEntry
+	write := func(e HSTSEntry, index, total int, _ any) bool {
+		if index == 0 {
+			saved = make([]HSTSEntry, 0, total)
+		}
+		saved = append(saved, e)
+		if index == total-1 {
+			store.Save(saved)
+			saved = nil
+		}
+		return true
+	}
+
+	if err := curl.Setopt(OPT_HSTS_CTRL, HSTS_ENABLE); err != nil {
+		return err
+	}
+	if err := curl.Setopt(OPT_HSTSREADFUNCTION, read); err != nil {
+		return err
+	}
+	return curl.Setopt(OPT_HSTSWRITEFUNCTION, write)
+}
+
+// hstsRead fills in the next entry for libcurl's read callback. It returns
+// one of STS_OK, STS_DONE or STS_FAIL.
+func (curl *CURL) hstsRead(maxHostLen int) (host string, includeSubDomains bool, expire string, status int) {
+	if curl.hstsReadFunction == nil {
+		return "", false, "", STS_FAIL
+	}
+	e, ok := (*curl.hstsReadFunction)(curl.hstsReadData)
+	if !ok {
+		return "", false, "", STS_DONE
+	}
+	if len(e.Host) >= maxHostLen {
+		warnf("curl: HSTS host %q does not fit libcurl buffer of %d bytes, skipped", e.Host, maxHostLen)
+		return curl.hstsRead(maxHostLen)
+	}
+	return e.Host, e.IncludeSubDomains, e.expireString(), STS_OK
+}
+
+// hstsWrite hands an entry from libcurl's write callback to the Go function.
+func (curl *CURL) hstsWrite(host string, includeSubDomains bool, expire string, index, total int) int {
+	if curl.hstsWriteFunction == nil {
+		return STS_FAIL
+	}
+	e := HSTSEntry{Host: host, IncludeSubDomains: includeSubDomains, Expire: parseHSTSExpire(expire)}
+	if (*curl.hstsWriteFunction)(e, index, total, curl.hstsWriteData) {
+		return STS_OK
+	}
+	return STS_FAIL
+}
@@ -0,0 +1,51 @@
+package curl
+
+import (
+	"testing"
+	"time"
+)
+
+type memoryHSTSStore struct {
+	entries []HSTSEntry
+	saved   []HSTSEntry
+}
+
+func (s *memoryHSTSStore) Load() []HSTSEntry  { return s.entries }
+func (s *memoryHSTSStore) Save(e []HSTSEntry) { s.saved = e }
+
+func TestHSTSStoreRoundTrip(t *testing.T) {
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	expire := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := &memoryHSTSStore{entries: []HSTSEntry{
+		{Host: "example.com", IncludeSubDomains: true, Expire: expire},
+		{Host: "example.org"},
+	}}
+	if err := easy.SetHSTSStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	host, sub, exp, status := easy.hstsRead(256)
+	if status != STS_OK || host != "example.com" || !sub || exp != "20300102 03:04:05" {
+		t.Errorf("unexpected first entry: %q %t %q %d", host, sub, exp, status)
+	}
+	if _, _, _, status = easy.hstsRead(256); status != STS_OK {
+		t.Errorf("second entry status should be %d and is %d.", STS_OK, status)
+	}
+	if _, _, _, status = easy.hstsRead(256); status != STS_DONE {
+		t.Errorf("status after last entry should be %d and is %d.", STS_DONE, status)
+	}
+
+	easy.hstsWrite("example.net", false, "20300102 03:04:05", 0, 2)
+	easy.hstsWrite("example.com", true, "", 1, 2)
+	if len(store.saved) != 2 {
+		t.Fatalf("store should have 2 saved entries and has %d.", len(store.saved))
+	}
+	if !store.saved[0].Expire.Equal(expire) {
+		t.Errorf("expire should be %v and is %v.", expire, store.saved[0].Expire)
+	}
+	if !store.saved[1].Expire.IsZero() || !store.saved[1].IncludeSubDomains {
+		t.Errorf("unexpected second saved entry: %+v", store.saved[1])
+	}
+}
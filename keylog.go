@@ -0,0 +1,80 @@
+package curl
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// keyLogWriter serializes NSS key log lines from concurrent connections.
+type keyLogWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (k *keyLogWriter) writeLine(line string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	io.WriteString(k.w, line+"\n")
+}
+
+// package-wide key log writer, see SetKeyLogWriter
+var globalKeyLog atomic.Pointer[keyLogWriter]
+
+// SetKeyLogWriter writes the TLS secrets of every handle without its own key
+// log writer to w, in the NSS key log format Wireshark reads from
+// SSLKEYLOGFILE. It takes effect for handles created or reset afterwards and
+// for new connections of handles that already log keys. Passing nil stops
+// logging.
+//
+// Key logging uses the BoringSSL context and is not available on Windows.
+func SetKeyLogWriter(w io.Writer) {
+	if w == nil {
+		globalKeyLog.Store(nil)
+		return
+	}
+	globalKeyLog.Store(&keyLogWriter{w: w})
+}
+
+// SetKeyLogWriter writes the TLS secrets of this handle's new connections to
+// w in NSS key log format, overriding the package-wide writer set with
+// SetKeyLogWriter. It works alongside OPT_SSL_CTX_FUNCTION. Connections that
+// are already established are not logged.
+func (curl *CURL) SetKeyLogWriter(w io.Writer) error {
+	if w == nil {
+		curl.keyLogWriter = nil
+		return nil
+	}
+	curl.keyLogWriter = &keyLogWriter{w: w}
+	return curl.installSSLCtxCallback()
+}
+
+func (curl *CURL) keyLog() *keyLogWriter {
+	if curl.keyLogWriter != nil {
+		return curl.keyLogWriter
+	}
+	return globalKeyLog.Load()
+}
+
+// installSSLCtxCallback points OPT_SSL_CTX_FUNCTION at the Go trampoline,
+// which runs the key log setup and the user's SSL context function.
+func (curl *CURL) installSSLCtxCallback() error {
+	p := curl.handle
+	if errCode := curl.setUserdata(OPT_SSL_CTX_DATA); errCode != 0 {
+		return newCurlError(errCode)
+	}
+	return newCurlError(CurlEasySetoptFunction(p, int(OPT_SSL_CTX_FUNCTION), GetSSLCtxCallbackFuncptr()))
+}
+
+// setupKeyLog enables key logging on a new SSL context. Failing to do so
+// is logged but does not fail the connection.
+func (curl *CURL) setupKeyLog(ctx *SSLContext) {
+	k := curl.keyLog()
+	if k == nil {
+		return
+	}
+	if err := ctx.SetKeyLogFunc(k.writeLine); err != nil {
+		curl.logAttrs(_WARN, "curl: failed to enable TLS key logging", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,38 @@
+package curl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKeyLogWriter(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	var buf bytes.Buffer
+	if err := easy.SetKeyLogWriter(&buf); err != nil {
+		t.Fatal(err)
+	}
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_SSL_VERIFYPEER, false)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if buf.Len() == 0 || len(lines) == 0 {
+		t.Fatal("no key log lines were written.")
+	}
+	for _, line := range lines {
+		if len(strings.Fields(line)) != 3 {
+			t.Errorf("key log line %q is not in NSS key log format.", line)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package curl
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
 )
 
 const (
@@ -15,6 +21,9 @@ const _DEFAULT_LOG_LEVEL = _WARN
 
 var log_level = _DEFAULT_LOG_LEVEL
 
+// package-wide structured logger, see SetLogger
+var slogger atomic.Pointer[slog.Logger]
+
 // SetLogLevel changes the log level which determines the granularity of the
 // messages that are logged.  Available log levels are: "DEBUG", "INFO",
 // "WARN", "ERROR" and "DEFAULT_LOG_LEVEL".
@@ -33,7 +42,43 @@ func SetLogLevel(levelName string) {
 	}
 }
 
+// SetLogger routes all messages of the package, including libcurl verbose
+// output of handles without their own logger, to l. Level filtering is then
+// left to l's handler. Passing nil restores the standard log package.
+func SetLogger(l *slog.Logger) {
+	slogger.Store(l)
+}
+
+// SetLogger sets a logger for this handle only, overriding the package-wide
+// logger set with SetLogger.
+func (curl *CURL) SetLogger(l *slog.Logger) {
+	curl.slogger = l
+}
+
+func (curl *CURL) logger() *slog.Logger {
+	if curl.slogger != nil {
+		return curl.slogger
+	}
+	return slogger.Load()
+}
+
+func slogLevel(limitLevel int) slog.Level {
+	switch {
+	case limitLevel <= _DEBUG:
+		return slog.LevelDebug
+	case limitLevel <= _INFO:
+		return slog.LevelInfo
+	case limitLevel <= _WARN:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
 func logf(limitLevel int, format string, args ...any) {
+	if l := slogger.Load(); l != nil {
+		l.Log(context.Background(), slogLevel(limitLevel), fmt.Sprintf(format, args...))
+		return
+	}
 	if log_level <= limitLevel {
 		log.Printf(format, args...)
 	}
@@ -54,3 +99,81 @@ func warnf(format string, args ...any) {
 func errorf(format string, args ...any) {
 	logf(_ERROR, format, args...)
 }
+
+// logAttrs logs msg for this handle, tagged with its id and URL.
+func (curl *CURL) logAttrs(limitLevel int, msg string, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{slog.Uint64("handle", curl.id)}, attrs...)
+	if curl.url != "" {
+		attrs = append(attrs, slog.String("url", curl.url))
+	}
+	if l := curl.logger(); l != nil {
+		l.LogAttrs(context.Background(), slogLevel(limitLevel), msg, attrs...)
+		return
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, a := range attrs {
+		b.WriteString(" ")
+		b.WriteString(a.String())
+	}
+	logf(limitLevel, "%s", b.String())
+}
+
+func debugInfoTypeName(infoType Info) string {
+	switch infoType {
+	case INFO_TEXT:
+		return "text"
+	case INFO_HEADER_IN:
+		return "header_in"
+	case INFO_HEADER_OUT:
+		return "header_out"
+	case INFO_DATA_IN:
+		return "data_in"
+	case INFO_DATA_OUT:
+		return "data_out"
+	case INFO_SSL_DATA_IN:
+		return "ssl_data_in"
+	case INFO_SSL_DATA_OUT:
+		return "ssl_data_out"
+	}
+	return fmt.Sprintf("unknown(%d)", infoType)
+}
+
+// verboseDebugFunction is installed as OPT_DEBUGFUNCTION when OPT_VERBOSE is
+// enabled without a user debug function. With a logger configured the
+// verbose output becomes debug records; otherwise it is written to stderr
+// the way libcurl does by default.
+func (curl *CURL) verboseDebugFunction(infoType Info, data []byte, _ any) {
+	l := curl.logger()
+	if l == nil {
+		var prefix string
+		switch infoType {
+		case INFO_TEXT:
+			prefix = "* "
+		case INFO_HEADER_IN:
+			prefix = "< "
+		case INFO_HEADER_OUT:
+			prefix = "> "
+		default:
+			return
+		}
+		var b strings.Builder
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line != "" {
+				b.WriteString(prefix)
+				b.WriteString(line)
+			}
+		}
+		os.Stderr.WriteString(b.String())
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("info_type", debugInfoTypeName(infoType))}
+	switch infoType {
+	case INFO_TEXT, INFO_HEADER_IN, INFO_HEADER_OUT:
+		attrs = append(attrs, slog.String("text", strings.TrimRight(string(data), "\r\n")))
+	default:
+		attrs = append(attrs, slog.Int("size", len(data)))
+	}
+	curl.logAttrs(_DEBUG, "libcurl", attrs...)
+}
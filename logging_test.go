@@ -8,6 +8,8 @@ import (
 	"os"
 	"fmt"
 	"regexp"
+	"log/slog"
+	"strings"
 )
 
 func TestDefaultLogLevel(t *testing.T) {
@@ -62,3 +64,56 @@ func TestLogfUsesLogLevel(t *testing.T) {
         t.Errorf("log output should match %q and is %q.", expectedLine, line)
     }
 }
+
+func TestSetLoggerRoutesLogf(t *testing.T) {
+	buf := new(bytes.Buffer)
+	SetLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer SetLogger(nil)
+
+	logf(_DEBUG, testFormat, testArgument)
+	if !strings.Contains(buf.String(), `"level":"DEBUG"`) || !strings.Contains(buf.String(), fmt.Sprintf(testFormat, testArgument)) {
+		t.Errorf("slog output should contain the debug message and is %q.", buf.String())
+	}
+}
+
+func TestHandleLoggerAttrs(t *testing.T) {
+	buf := new(bytes.Buffer)
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.SetLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	easy.Setopt(OPT_URL, "http://example.com/")
+
+	easy.verboseDebugFunction(INFO_TEXT, []byte("Connected\n"), nil)
+	line := buf.String()
+	for _, want := range []string{
+		fmt.Sprintf(`"handle":%d`, easy.id),
+		`"url":"http://example.com/"`,
+		`"info_type":"text"`,
+		`"text":"Connected"`,
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log output should contain %s and is %q.", want, line)
+		}
+	}
+}
+
+func TestVerboseDebugFunctionOwner(t *testing.T) {
+	buf := new(bytes.Buffer)
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.SetLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	easy.Setopt(OPT_VERBOSE, true)
+
+	dup := easy.Duphandle()
+	defer dup.Cleanup()
+	(*dup.debugFunction)(INFO_TEXT, []byte("Connected\n"), nil)
+	if want := fmt.Sprintf(`"handle":%d`, dup.id); !strings.Contains(buf.String(), want) {
+		t.Errorf("duplicate should log verbose output as itself, %s, and logged %q.", want, buf.String())
+	}
+
+	easy.Setopt(OPT_DEBUGFUNCTION, func(Info, []byte, any) {})
+	easy.Setopt(OPT_DEBUGFUNCTION, nil)
+	if !easy.verboseDebug || easy.debugFunction == nil {
+		t.Error("clearing the debug function in verbose mode should log to the logger again.")
+	}
+}
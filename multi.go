@@ -4,8 +4,10 @@ package curl
 
 import "C" // Keep if using C.int, C.long for variables passed to wrappers.
 import (
+	"errors"
 	"fmt"
 	// "syscall" // No longer needed for FdSet
+	"time"
 	"unsafe"
 )
 
@@ -35,6 +37,9 @@ type CURLMessage struct {
 	Easy_handle *CURL
 	DoneResult  Code
 	PointerVal  unsafe.Pointer
+	// Err is the transfer result for CURLMSG_DONE messages, as seen by
+	// Perform callers.
+	Err error
 }
 
 // newCURLMessage remains the same
@@ -48,18 +53,20 @@ func newCURLMessage(opaqueCM CurlMsg) *CURLMessage {
 
 	easyHandlePtr := CurlMsgGetEasyHandle(opaqueCM) // Use accessor (returns unsafe.Pointer)
 	if easyHandlePtr != nil {
-		// Assuming context_map is properly defined and accessible for mapping C handles to Go *CURL structs
-		// goEasyHandle := context_map.Get(uintptr(easyHandlePtr))
-		// if goEasyHandle != nil {
-		// 	goMsg.Easy_handle = goEasyHandle
-		// } else {
-		// For simplicity if context_map isn't shown or fully implemented for multi:
-		goMsg.Easy_handle = &CURL{handle: easyHandlePtr}
-		// }
+		if goEasyHandle := curlFromEasy(easyHandlePtr); goEasyHandle != nil {
+			goMsg.Easy_handle = goEasyHandle
+		} else {
+			goMsg.Easy_handle = &CURL{handle: easyHandlePtr}
+		}
 	}
 
 	if goMsg.Msg == GetCurlmsgDone() {
 		goMsg.DoneResult = CurlMsgGetResult(opaqueCM) // Use accessor
+		goMsg.Err = newCurlError(CurlCode(goMsg.DoneResult))
+		if goMsg.Easy_handle != nil {
+			goMsg.Err = goMsg.Easy_handle.newError(CurlCode(goMsg.DoneResult))
+			goMsg.Err = goMsg.Easy_handle.transferDone(goMsg.Err)
+		}
 	} else {
 		goMsg.PointerVal = CurlMsgGetWhatever(opaqueCM) // Use accessor
 	}
@@ -68,6 +75,78 @@ func newCURLMessage(opaqueCM CurlMsg) *CURLMessage {
 
 type CURLM struct {
 	handle unsafe.Pointer
+	// addHooks run for every easy handle passed to AddHandle, before its
+	// own transfer hooks.
+	addHooks []func(*CURL) error
+	// served holds completion messages for transfers that a transfer hook
+	// served without libcurl; Info_read returns them first.
+	served        []*CURLMessage
+	servedHandles map[*CURL]bool
+	// admitHooks may hold back a handle passed to AddHandle until the
+	// returned time; it is then added on the first Perform after it.
+	admitHooks []func(*CURL) (time.Time, bool)
+	// doneHooks see every completion message before Info_read returns it;
+	// returning true swallows the message.
+	doneHooks []func(*CURLMessage) bool
+	// requeued holds handles to add again once their time has come, see
+	// requeue.
+	requeued []requeuedHandle
+	// active holds the handles libcurl runs a transfer for whose completion
+	// message has not been read yet.
+	active map[*CURL]bool
+	// removeHooks run for every easy handle passed to RemoveHandle.
+	removeHooks []func(*CURL)
+}
+
+type requeuedHandle struct {
+	easy *CURL
+	at   time.Time
+}
+
+// requeue removes easy from the multi handle and adds it again on the
+// first Perform at or after at.
+func (mcurl *CURLM) requeue(easy *CURL, at time.Time) error {
+	if err := mcurl.remove(easy); err != nil {
+		return err
+	}
+	mcurl.requeued = append(mcurl.requeued, requeuedHandle{easy: easy, at: at})
+	return nil
+}
+
+// addRequeued adds the requeued handles that are due. A handle that fails
+// to be added completes with the error; one that an admit hook holds back
+// again stays queued.
+func (mcurl *CURLM) addRequeued() {
+	if len(mcurl.requeued) == 0 {
+		return
+	}
+	now := time.Now()
+	queued := mcurl.requeued
+	mcurl.requeued = nil
+	var pending []requeuedHandle
+	for _, r := range queued {
+		if now.Before(r.at) {
+			pending = append(pending, r)
+			continue
+		}
+		at, deferred, err := mcurl.add(r.easy)
+		if deferred {
+			pending = append(pending, requeuedHandle{easy: r.easy, at: at})
+		} else if err != nil {
+			mcurl.complete(&CURLMessage{Msg: GetCurlmsgDone(), Easy_handle: r.easy, DoneResult: Code(E_FAILED_INIT), Err: err})
+		}
+	}
+	// Transfers served while adding may have requeued their handles.
+	mcurl.requeued = append(pending, mcurl.requeued...)
+}
+
+// complete queues msg for a transfer that finished without libcurl.
+func (mcurl *CURLM) complete(msg *CURLMessage) {
+	if mcurl.servedHandles == nil {
+		mcurl.servedHandles = make(map[*CURL]bool)
+	}
+	mcurl.servedHandles[msg.Easy_handle] = true
+	mcurl.served = append(mcurl.served, msg)
 }
 
 // MultiInit, Cleanup, Perform, AddHandle, RemoveHandle, Timeout, Setopt
@@ -86,6 +165,19 @@ func (mcurl *CURLM) Cleanup() error {
 	if mcurl.handle == nil {
 		return nil
 	}
+	// Let the hooks of unfinished transfers see them end. Handles cleaned
+	// up already have dropped their hooks.
+	for easy := range mcurl.active {
+		if easy.handle != nil {
+			mcurl.RemoveHandle(easy)
+		}
+	}
+	for _, r := range mcurl.requeued {
+		for _, hook := range mcurl.removeHooks {
+			hook(r.easy)
+		}
+	}
+	mcurl.requeued = nil
 	err := newCurlMultiError(CurlMultiCleanup(MultiHandle(mcurl.handle)))
 	mcurl.handle = nil
 	return err
@@ -95,9 +187,11 @@ func (mcurl *CURLM) Perform() (int, error) {
 	if mcurl.handle == nil {
 		return 0, fmt.Errorf("curl: multi handle is nil")
 	}
+	mcurl.addRequeued()
 	var runningHandles C.int = -1 // C.int might be int32
 	err := newCurlMultiError(CurlMultiPerform(MultiHandle(mcurl.handle), unsafe.Pointer(&runningHandles)))
-	return int(runningHandles), err
+	// Requeued handles are still running from the caller's point of view.
+	return int(runningHandles) + len(mcurl.requeued), err
 }
 
 func (mcurl *CURLM) AddHandle(easy *CURL) error {
@@ -107,9 +201,56 @@ func (mcurl *CURLM) AddHandle(easy *CURL) error {
 	if easy == nil || easy.handle == nil {
 		return fmt.Errorf("curl: easy handle is nil")
 	}
-	return newCurlMultiError(CurlMultiAddHandle(MultiHandle(mcurl.handle), easy.handle))
+	for _, hook := range mcurl.addHooks {
+		if err := hook(easy); err != nil {
+			return err
+		}
+	}
+	at, deferred, err := mcurl.add(easy)
+	if deferred {
+		mcurl.requeued = append(mcurl.requeued, requeuedHandle{easy: easy, at: at})
+	}
+	return err
+}
+
+// add starts the transfer of easy, unless an admit hook holds it back
+// until the returned time.
+func (mcurl *CURLM) add(easy *CURL) (time.Time, bool, error) {
+	for _, admit := range mcurl.admitHooks {
+		if at, ok := admit(easy); !ok {
+			return at, true, nil
+		}
+	}
+	if err := easy.transferStart(); err != nil {
+		return time.Time{}, false, err
+	}
+	if served, err := easy.transferServe(); served {
+		msg := &CURLMessage{Msg: GetCurlmsgDone(), Easy_handle: easy, DoneResult: Code(E_OK)}
+		msg.Err = easy.transferDone(err)
+		if msg.Err != nil {
+			msg.DoneResult = Code(E_ABORTED_BY_CALLBACK)
+			var ce CurlError
+			if errors.As(msg.Err, &ce) {
+				msg.DoneResult = Code(ce)
+			}
+		}
+		mcurl.complete(msg)
+		return time.Time{}, false, nil
+	}
+	if err := newCurlMultiError(CurlMultiAddHandle(MultiHandle(mcurl.handle), easy.handle)); err != nil {
+		// The transfer has started, so its hooks must see it end.
+		return time.Time{}, false, easy.transferDone(err)
+	}
+	if mcurl.active == nil {
+		mcurl.active = make(map[*CURL]bool)
+	}
+	mcurl.active[easy] = true
+	return time.Time{}, false, nil
 }
 
+// RemoveHandle takes easy off the multi handle. A transfer that has not
+// completed yet ends with E_ABORTED_BY_CALLBACK as far as the handle's
+// helpers are concerned.
 func (mcurl *CURLM) RemoveHandle(easy *CURL) error {
 	if mcurl.handle == nil {
 		return fmt.Errorf("curl: multi handle is nil")
@@ -117,6 +258,42 @@ func (mcurl *CURLM) RemoveHandle(easy *CURL) error {
 	if easy == nil || easy.handle == nil {
 		return fmt.Errorf("curl: easy handle is nil to remove")
 	}
+	for _, hook := range mcurl.removeHooks {
+		hook(easy)
+	}
+	if !mcurl.active[easy] {
+		return mcurl.remove(easy)
+	}
+	delete(mcurl.active, easy)
+	err := mcurl.remove(easy)
+	easy.transferDone(CurlError(E_ABORTED_BY_CALLBACK))
+	return err
+}
+
+// abort removes easy while its transfer is running and ends the transfer
+// with cause, returning the transfer's result.
+func (mcurl *CURLM) abort(easy *CURL, cause error) error {
+	if !mcurl.active[easy] {
+		mcurl.remove(easy)
+		return cause
+	}
+	delete(mcurl.active, easy)
+	mcurl.remove(easy)
+	return easy.transferDone(cause)
+}
+
+// remove takes easy off the multi handle without running any hooks.
+func (mcurl *CURLM) remove(easy *CURL) error {
+	if mcurl.servedHandles[easy] {
+		delete(mcurl.servedHandles, easy)
+		return nil
+	}
+	for i, r := range mcurl.requeued {
+		if r.easy == easy {
+			mcurl.requeued = append(mcurl.requeued[:i:i], mcurl.requeued[i+1:]...)
+			return nil
+		}
+	}
 	return newCurlMultiError(CurlMultiRemoveHandle(MultiHandle(mcurl.handle), easy.handle))
 }
 
@@ -126,7 +303,17 @@ func (mcurl *CURLM) Timeout() (int, error) {
 	}
 	var timeoutMs C.long = -1 // C.long can be int32 or int64 depending on platform
 	err := newCurlMultiError(CurlMultiTimeout(MultiHandle(mcurl.handle), unsafe.Pointer(&timeoutMs)))
-	return int(timeoutMs), err
+	timeout := int(timeoutMs)
+	for _, r := range mcurl.requeued {
+		due := int(time.Until(r.at).Milliseconds())
+		if due < 0 {
+			due = 0
+		}
+		if timeout < 0 || due < timeout {
+			timeout = due
+		}
+	}
+	return timeout, err
 }
 
 func (mcurl *CURLM) Setopt(opt int, param any) error {
@@ -185,7 +372,49 @@ func (mcurl *CURLM) Info_read() (*CURLMessage, int) {
 	if mcurl.handle == nil {
 		return nil, 0
 	}
+	for {
+		msg, left := mcurl.nextMessage()
+		if msg == nil || !mcurl.swallow(msg) {
+			return msg, left
+		}
+	}
+}
+
+func (mcurl *CURLM) nextMessage() (*CURLMessage, int) {
+	if len(mcurl.served) > 0 {
+		msg := mcurl.served[0]
+		mcurl.served = mcurl.served[1:]
+		return msg, len(mcurl.served)
+	}
 	var msgsInQueue C.int = 0
 	opaqueCM := CurlMultiInfoRead(MultiHandle(mcurl.handle), unsafe.Pointer(&msgsInQueue))
-	return newCURLMessage(opaqueCM), int(msgsInQueue)
+	msg := newCURLMessage(opaqueCM)
+	if msg != nil && msg.Msg == GetCurlmsgDone() {
+		delete(mcurl.active, msg.Easy_handle)
+	}
+	return msg, int(msgsInQueue)
+}
+
+func (mcurl *CURLM) swallow(msg *CURLMessage) bool {
+	if msg.Msg != GetCurlmsgDone() || msg.Easy_handle == nil {
+		return false
+	}
+	for _, hook := range mcurl.doneHooks {
+		if hook(msg) {
+			return true
+		}
+	}
+	easy := msg.Easy_handle
+	if msg.Err != nil || easy.redirect == nil {
+		return false
+	}
+	follow, err := easy.followRedirect()
+	if err == nil && follow {
+		err = mcurl.requeue(easy, time.Now())
+		if err == nil {
+			return true
+		}
+	}
+	msg.Err = err
+	return false
 }
@@ -0,0 +1,37 @@
+package curl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewEasy(t *testing.T) {
+	var gotHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Test")
+	}))
+	defer ts.Close()
+
+	easy, err := NewEasy(
+		WithTimeout(5*time.Second),
+		WithHeaders("X-Test: yes"),
+		WithOption(OPT_URL, ts.URL),
+		WithOption(OPT_WRITEFUNCTION, func([]byte, any) bool { return true }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer easy.Cleanup()
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if gotHeader != "yes" {
+		t.Errorf("X-Test header should be %q and is %q.", "yes", gotHeader)
+	}
+
+	if _, err := NewEasy(WithImpersonation("no-such-browser", true)); err == nil {
+		t.Error("NewEasy should fail for an unknown impersonation target.")
+	}
+}
@@ -0,0 +1,170 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var proxyCodeNames = [...]string{
+	"OK", "BAD_ADDRESS_TYPE", "BAD_VERSION", "CLOSED", "GSSAPI", "GSSAPI_PERMSG",
+	"GSSAPI_PROTECTION", "IDENTD", "IDENTD_DIFFER", "LONG_HOSTNAME", "LONG_PASSWD",
+	"LONG_USER", "NO_AUTH", "RECV_ADDRESS", "RECV_AUTH", "RECV_CONNECT", "RECV_REQACK",
+	"REPLY_ADDRESS_TYPE_NOT_SUPPORTED", "REPLY_COMMAND_NOT_SUPPORTED",
+	"REPLY_CONNECTION_REFUSED", "REPLY_GENERAL_SERVER_FAILURE", "REPLY_HOST_UNREACHABLE",
+	"REPLY_NETWORK_UNREACHABLE", "REPLY_NOT_ALLOWED", "REPLY_TTL_EXPIRED",
+	"REPLY_UNASSIGNED", "REQUEST_FAILED", "RESOLVE_HOST", "SEND_AUTH", "SEND_CONNECT",
+	"SEND_REQUEST", "UNKNOWN_FAIL", "UNKNOWN_MODE", "USER_REJECTED",
+}
+
+func (c ProxyCode) String() string {
+	if int(c) < len(proxyCodeNames) {
+		return "PX_" + proxyCodeNames[c]
+	}
+	return fmt.Sprintf("PX_%d", uint32(c))
+}
+
+// ProxyErrorKind tells whose fault a failed proxy transfer was.
+type ProxyErrorKind int
+
+const (
+	// ProxyUnavailable means the proxy could not be resolved, reached or
+	// spoken to.
+	ProxyUnavailable ProxyErrorKind = iota
+	// ProxyAuthFailed means the proxy rejected the credentials (CONNECT 407,
+	// no acceptable SOCKS authentication method, SOCKS4 user rejected).
+	ProxyAuthFailed
+	// ProxyRejected means the proxy refused to serve the target, e.g. with
+	// CONNECT 403 or a SOCKS "not allowed" reply.
+	ProxyRejected
+	// ProxyUpstreamFailed means the proxy works but could not reach the
+	// target (CONNECT 5xx, SOCKS connection refused or host unreachable).
+	ProxyUpstreamFailed
+)
+
+func (k ProxyErrorKind) String() string {
+	switch k {
+	case ProxyUnavailable:
+		return "proxy unavailable"
+	case ProxyAuthFailed:
+		return "proxy authentication failed"
+	case ProxyRejected:
+		return "proxy rejected the request"
+	case ProxyUpstreamFailed:
+		return "proxy could not reach the target"
+	}
+	return fmt.Sprintf("ProxyErrorKind(%d)", int(k))
+}
+
+// ProxyError is returned by Perform and in CURLMessage.Err when a transfer
+// failed at the proxy. It wraps the libcurl error, so errors.Is still
+// matches CurlError values.
+type ProxyError struct {
+	Kind ProxyErrorKind
+	// ConnectCode is the status of the proxy's CONNECT response
+	// (INFO_HTTP_CONNECTCODE), or 0 without a CONNECT.
+	ConnectCode int
+	// SOCKSError is INFO_PROXY_ERROR, PX_OK for non-SOCKS failures.
+	SOCKSError ProxyCode
+	// Headers are the CONNECT response header lines, including the status
+	// line, if the proxy was set with OPT_PROXY on this handle.
+	Headers []string
+	Err     error
+}
+
+func (e *ProxyError) Error() string {
+	var b strings.Builder
+	b.WriteString("curl: ")
+	b.WriteString(e.Kind.String())
+	switch {
+	case e.ConnectCode != 0:
+		fmt.Fprintf(&b, " (CONNECT %d)", e.ConnectCode)
+	case e.SOCKSError != PX_OK:
+		fmt.Fprintf(&b, " (%s)", e.SOCKSError)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// connectHeaders records the first header block of every transfer, which
+// is the CONNECT response when the transfer was tunneled.
+type connectHeaders struct {
+	lines []string
+	done  bool
+	tap   func([]byte)
+}
+
+func (curl *CURL) captureConnectHeaders() error {
+	if curl.connectHeaders != nil {
+		return nil
+	}
+	h := &connectHeaders{}
+	h.tap = func(line []byte) {
+		if h.done {
+			return
+		}
+		h.lines = append(h.lines, string(line))
+		if strings.TrimRight(string(line), "\r\n") == "" {
+			h.done = true
+		}
+	}
+	curl.connectHeaders = h
+	return curl.addHeaderTap(&h.tap)
+}
+
+// proxyError turns err into a *ProxyError if it happened at the proxy.
+func (curl *CURL) proxyError(err error) error {
+	var ce CurlError
+	if err == nil || !errors.As(err, &ce) {
+		return err
+	}
+	pe := &ProxyError{
+		ConnectCode: int(curl.getinfoInt(INFO_HTTP_CONNECTCODE)),
+		SOCKSError:  ProxyCode(curl.getinfoInt(INFO_PROXY_ERROR)),
+		Err:         err,
+	}
+	switch {
+	case pe.SOCKSError != PX_OK:
+		pe.Kind = socksErrorKind(pe.SOCKSError)
+	case pe.ConnectCode >= 300:
+		switch {
+		case pe.ConnectCode == 407:
+			pe.Kind = ProxyAuthFailed
+		case pe.ConnectCode >= 500:
+			pe.Kind = ProxyUpstreamFailed
+		default:
+			pe.Kind = ProxyRejected
+		}
+	case ce == E_COULDNT_RESOLVE_PROXY || ce == E_PROXY:
+		pe.Kind = ProxyUnavailable
+	case ce == E_COULDNT_CONNECT && curl.getinfoInt(INFO_USED_PROXY) != 0:
+		// The proxy is all libcurl connects to, unless OPT_NOPROXY
+		// matched the host.
+		pe.Kind = ProxyUnavailable
+	default:
+		return err
+	}
+	if h := curl.connectHeaders; h != nil && pe.ConnectCode != 0 {
+		pe.Headers = h.lines
+	}
+	return pe
+}
+
+func socksErrorKind(code ProxyCode) ProxyErrorKind {
+	switch code {
+	case PX_NO_AUTH, PX_USER_REJECTED, PX_LONG_USER, PX_LONG_PASSWD:
+		return ProxyAuthFailed
+	case PX_REPLY_NOT_ALLOWED, PX_REQUEST_FAILED, PX_REPLY_COMMAND_NOT_SUPPORTED,
+		PX_REPLY_ADDRESS_TYPE_NOT_SUPPORTED:
+		return ProxyRejected
+	case PX_REPLY_CONNECTION_REFUSED, PX_REPLY_HOST_UNREACHABLE, PX_REPLY_NETWORK_UNREACHABLE,
+		PX_REPLY_TTL_EXPIRED, PX_REPLY_GENERAL_SERVER_FAILURE, PX_RESOLVE_HOST:
+		return ProxyUpstreamFailed
+	}
+	return ProxyUnavailable
+}
@@ -0,0 +1,86 @@
+package curl
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProxyErrorConnect(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="test"`)
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	defer proxy.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, "https://example.invalid/")
+	if err := easy.SetProxy(&ProxyConfig{URL: proxy.URL, Type: PROXY_HTTP, Tunnel: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := easy.Perform()
+	var pe *ProxyError
+	if !errors.As(err, &pe) {
+		t.Fatalf("error should be a *ProxyError and is %v.", err)
+	}
+	if pe.Kind != ProxyAuthFailed {
+		t.Errorf("kind should be %v and is %v.", ProxyAuthFailed, pe.Kind)
+	}
+	if pe.ConnectCode != 407 {
+		t.Errorf("connect code should be 407 and is %d.", pe.ConnectCode)
+	}
+	var found bool
+	for _, h := range pe.Headers {
+		if strings.HasPrefix(h, "Proxy-Authenticate:") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("headers should contain Proxy-Authenticate and are %q.", pe.Headers)
+	}
+	var ce CurlError
+	if !errors.As(err, &ce) {
+		t.Errorf("error should wrap the libcurl error and is %v.", pe.Err)
+	}
+}
+
+func TestProxyErrorUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := "http://" + l.Addr().String()
+	l.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, "http://example.invalid/")
+	easy.Setopt(OPT_PROXY, dead)
+
+	var pe *ProxyError
+	if err := easy.Perform(); !errors.As(err, &pe) || pe.Kind != ProxyUnavailable {
+		t.Errorf("error should be an unavailable proxy and is %v.", err)
+	}
+
+	// A host matched by OPT_NOPROXY is connected to directly.
+	easy.Setopt(OPT_URL, dead+"/")
+	easy.Setopt(OPT_NOPROXY, "127.0.0.1")
+	err = easy.Perform()
+	if errors.As(err, &pe) || !errors.Is(err, CurlError(E_COULDNT_CONNECT)) {
+		t.Errorf("error should be a direct connection failure and is %v.", err)
+	}
+}
+
+func TestProxyCodeString(t *testing.T) {
+	if s := PX_REPLY_CONNECTION_REFUSED.String(); s != "PX_REPLY_CONNECTION_REFUSED" {
+		t.Errorf("name should be PX_REPLY_CONNECTION_REFUSED and is %s.", s)
+	}
+	if k := socksErrorKind(PX_NO_AUTH); k != ProxyAuthFailed {
+		t.Errorf("PX_NO_AUTH should be %v and is %v.", ProxyAuthFailed, k)
+	}
+}
@@ -0,0 +1,249 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoProxy is returned when a ProxyPool has no proxy left that is neither
+// cooling down nor banned.
+var ErrNoProxy = errors.New("curl: no proxy available")
+
+// ProxyPool rotates handles through a set of proxies. Every transfer of an
+// attached handle gets a proxy through CURL.SetProxy; its outcome feeds the
+// proxy's health. A proxy failing MaxFailures transfers in a row cools down,
+// and is banned after BanAfter cooldowns.
+type ProxyPool struct {
+	// MaxFailures is the number of consecutive failures after which a proxy
+	// cools down. Zero means 3.
+	MaxFailures int
+	// Cooldown is how long a failing proxy is skipped. Zero means a minute.
+	Cooldown time.Duration
+	// BanAfter bans a proxy for good once it has cooled down this many
+	// times, or right away when it rejects its credentials. Zero never bans.
+	BanAfter int
+
+	mu      sync.Mutex
+	proxies []*poolProxy
+	next    int
+	sticky  map[string]*poolProxy
+	handles map[*CURL]*poolHandle
+	now     func() time.Time
+}
+
+type poolProxy struct {
+	cfg *ProxyConfig
+	ProxyStats
+}
+
+type poolHandle struct {
+	key   string
+	proxy *poolProxy
+	hook  *transferHook
+}
+
+// ProxyStats is the health of one proxy of a pool.
+type ProxyStats struct {
+	URL string
+	// Successes and Failures count finished transfers.
+	Successes, Failures int
+	// ConsecutiveFailures resets on every success.
+	ConsecutiveFailures int
+	// Cooldowns counts how often the proxy was cooled down.
+	Cooldowns     int
+	CooldownUntil time.Time
+	Banned        bool
+	LastError     error
+}
+
+// NewProxyPool returns a pool of proxies given as URLs, see ParseProxy.
+func NewProxyPool(proxies ...string) (*ProxyPool, error) {
+	p := &ProxyPool{
+		sticky:  make(map[string]*poolProxy),
+		handles: make(map[*CURL]*poolHandle),
+		now:     time.Now,
+	}
+	for _, raw := range proxies {
+		cfg, err := ParseProxy(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(cfg)
+	}
+	if len(p.proxies) == 0 {
+		return nil, fmt.Errorf("curl: proxy pool needs at least one proxy")
+	}
+	return p, nil
+}
+
+// Add adds a proxy with its full configuration to the pool.
+func (p *ProxyPool) Add(cfg *ProxyConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.proxies = append(p.proxies, &poolProxy{cfg: cfg, ProxyStats: ProxyStats{URL: cfg.URL}})
+}
+
+// Attach assigns a proxy of the pool to every transfer of curl. Transfers
+// with the same non-empty key share a proxy for as long as it stays healthy.
+func (p *ProxyPool) Attach(curl *CURL, key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h, ok := p.handles[curl]; ok {
+		h.key = key
+		return
+	}
+	h := &poolHandle{key: key}
+	h.hook = &transferHook{
+		start: func(c *CURL) error { return p.assign(c, h) },
+		done:  func(c *CURL, err error) error { p.report(h, err); return err },
+		detach: func(c *CURL) {
+			p.mu.Lock()
+			if p.handles[c] == h {
+				delete(p.handles, c)
+			}
+			p.mu.Unlock()
+		},
+	}
+	p.handles[curl] = h
+	curl.addTransferHook(h.hook)
+}
+
+// AttachMulti attaches every handle subsequently added to m that is not
+// attached yet, without a sticky key.
+func (p *ProxyPool) AttachMulti(m *CURLM) {
+	m.addHooks = append(m.addHooks, func(easy *CURL) error {
+		p.mu.Lock()
+		_, ok := p.handles[easy]
+		p.mu.Unlock()
+		if !ok {
+			p.Attach(easy, "")
+		}
+		return nil
+	})
+}
+
+// Detach stops assigning proxies to curl. The last proxy stays set.
+func (p *ProxyPool) Detach(curl *CURL) {
+	p.mu.Lock()
+	h, ok := p.handles[curl]
+	delete(p.handles, curl)
+	p.mu.Unlock()
+	if ok {
+		curl.removeTransferHook(h.hook)
+	}
+}
+
+// Stats returns a snapshot of every proxy's health.
+func (p *ProxyPool) Stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]ProxyStats, len(p.proxies))
+	for i, pp := range p.proxies {
+		stats[i] = pp.ProxyStats
+	}
+	return stats
+}
+
+func (p *ProxyPool) available(pp *poolProxy, now time.Time) bool {
+	return !pp.Banned && !now.Before(pp.CooldownUntil)
+}
+
+// pick returns the next available proxy in round-robin order.
+func (p *ProxyPool) pick(now time.Time) *poolProxy {
+	for range p.proxies {
+		pp := p.proxies[p.next]
+		p.next = (p.next + 1) % len(p.proxies)
+		if p.available(pp, now) {
+			return pp
+		}
+	}
+	return nil
+}
+
+func (p *ProxyPool) assign(curl *CURL, h *poolHandle) error {
+	p.mu.Lock()
+	now := p.now()
+	pp := p.sticky[h.key]
+	if pp == nil || !p.available(pp, now) {
+		pp = p.pick(now)
+		if h.key != "" {
+			if pp != nil {
+				p.sticky[h.key] = pp
+			} else {
+				delete(p.sticky, h.key)
+			}
+		}
+	}
+	h.proxy = pp
+	p.mu.Unlock()
+
+	if pp == nil {
+		return ErrNoProxy
+	}
+	return curl.SetProxy(pp.cfg)
+}
+
+func (p *ProxyPool) report(h *poolHandle, err error) {
+	pp := h.proxy
+	if pp == nil {
+		return
+	}
+	failed := proxyFailed(err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !failed {
+		pp.Successes++
+		pp.ConsecutiveFailures = 0
+		return
+	}
+	pp.Failures++
+	pp.ConsecutiveFailures++
+	pp.LastError = err
+	var pe *ProxyError
+	if errors.As(err, &pe) && pe.Kind == ProxyAuthFailed && p.BanAfter > 0 {
+		// Wrong credentials do not get better by waiting.
+		pp.Banned = true
+		return
+	}
+	maxFailures := p.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	if pp.ConsecutiveFailures < maxFailures {
+		return
+	}
+	cooldown := p.Cooldown
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	pp.ConsecutiveFailures = 0
+	pp.Cooldowns++
+	pp.CooldownUntil = p.now().Add(cooldown)
+	if p.BanAfter > 0 && pp.Cooldowns >= p.BanAfter {
+		pp.Banned = true
+	}
+}
+
+// proxyFailed reports whether err, the result of a transfer through a
+// proxy, is the proxy's fault rather than the origin's.
+func proxyFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pe *ProxyError
+	if errors.As(err, &pe) {
+		return pe.Kind != ProxyUpstreamFailed
+	}
+	var ce CurlError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	switch ce {
+	case E_OPERATION_TIMEDOUT, E_SSL_CONNECT_ERROR, E_RECV_ERROR, E_SEND_ERROR, E_GOT_NOTHING:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package curl
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyPool(t *testing.T) {
+	var proxied int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+	}))
+	defer proxy.Close()
+
+	// A listener that is closed right away gives a port nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := "http://" + l.Addr().String()
+	l.Close()
+
+	pool, err := NewProxyPool(dead, proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.MaxFailures = 1
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, "http://example.invalid/")
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	pool.Attach(easy, "")
+
+	if err := easy.Perform(); err == nil {
+		t.Error("transfer through the dead proxy should fail.")
+	}
+	for i := 0; i < 2; i++ {
+		if err := easy.Perform(); err != nil {
+			t.Errorf("transfer %d should use the working proxy and failed: %v", i, err)
+		}
+	}
+	if proxied != 2 {
+		t.Errorf("working proxy should see 2 requests and saw %d.", proxied)
+	}
+
+	stats := pool.Stats()
+	if stats[0].Failures != 1 || stats[0].Cooldowns != 1 || stats[0].CooldownUntil.IsZero() {
+		t.Errorf("unexpected dead proxy stats: %+v", stats[0])
+	}
+	if stats[1].Successes != 2 || stats[1].Failures != 0 {
+		t.Errorf("unexpected working proxy stats: %+v", stats[1])
+	}
+}
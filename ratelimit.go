@@ -0,0 +1,236 @@
+package curl
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// concurrencyPoll is how often a transfer waiting for a free connection
+// slot checks again.
+const concurrencyPoll = 10 * time.Millisecond
+
+// RateLimiter keeps transfers within per-host budgets. It delays transfers
+// of attached handles, in Perform as well as in a multi handle, rather than
+// failing them.
+type RateLimiter struct {
+	// RequestsPerSecond limits how often transfers to a host start. Zero
+	// means unlimited.
+	RequestsPerSecond float64
+	// Burst is how many transfers may start at once after an idle period.
+	// Zero means 1.
+	Burst int
+	// MaxConcurrent limits the transfers running at once per host. Zero
+	// means unlimited.
+	MaxConcurrent int
+	// ByIP keys hosts on INFO_PRIMARY_IP once a transfer to them finished,
+	// so that names served by the same address share a budget.
+	ByIP bool
+	// MaxRecvSpeed and MaxSendSpeed cap every transfer in bytes per second
+	// through OPT_MAX_RECV_SPEED_LARGE and OPT_MAX_SEND_SPEED_LARGE. Zero
+	// means unlimited.
+	MaxRecvSpeed, MaxSendSpeed int64
+
+	mu      sync.Mutex
+	hosts   map[string]*hostBudget
+	ips     map[string]string
+	handles map[*CURL]*limitedHandle
+	now     func() time.Time
+}
+
+type hostBudget struct {
+	tokens  float64
+	last    time.Time
+	running int
+}
+
+type limitedHandle struct {
+	key  string
+	held bool
+	hook *transferHook
+}
+
+// Attach subjects every transfer of curl to the limits. The speed caps are
+// set right away.
+func (l *RateLimiter) Attach(curl *CURL) error {
+	if l.MaxRecvSpeed > 0 {
+		if err := curl.Setopt(OPT_MAX_RECV_SPEED_LARGE, l.MaxRecvSpeed); err != nil {
+			return err
+		}
+	}
+	if l.MaxSendSpeed > 0 {
+		if err := curl.Setopt(OPT_MAX_SEND_SPEED_LARGE, l.MaxSendSpeed); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.handles == nil {
+		l.handles = make(map[*CURL]*limitedHandle)
+	}
+	if _, ok := l.handles[curl]; ok {
+		return nil
+	}
+	h := &limitedHandle{}
+	h.hook = &transferHook{
+		// Acquiring in serve rather than start means no later hook can veto
+		// the transfer after the slot is taken; done releases it however the
+		// transfer ends, including removal from a multi handle.
+		serve: func(c *CURL) (bool, error) {
+			l.acquire(c, h)
+			return false, nil
+		},
+		done: func(c *CURL, err error) error {
+			l.release(c, h)
+			return err
+		},
+		detach: func(c *CURL) { l.forget(c, h) },
+	}
+	l.handles[curl] = h
+	curl.addTransferHook(h.hook)
+	return nil
+}
+
+// AttachMulti attaches every handle added to m and holds back handles
+// whose host is over budget until it has room again.
+func (l *RateLimiter) AttachMulti(m *CURLM) {
+	m.addHooks = append(m.addHooks, l.Attach)
+	m.admitHooks = append(m.admitHooks, func(easy *CURL) (time.Time, bool) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		now := l.clock()
+		wait, ok := l.ready(l.key(easy), now)
+		return now.Add(wait), ok
+	})
+}
+
+// Detach removes the limits from curl. The speed caps stay set.
+func (l *RateLimiter) Detach(curl *CURL) {
+	l.mu.Lock()
+	h, ok := l.handles[curl]
+	delete(l.handles, curl)
+	l.mu.Unlock()
+	if ok {
+		curl.removeTransferHook(h.hook)
+	}
+}
+
+func (l *RateLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// key returns the budget key of the handle's URL.
+func (l *RateLimiter) key(curl *CURL) string {
+	host := urlHost(curl.url)
+	if ip, ok := l.ips[host]; ok {
+		return ip
+	}
+	return host
+}
+
+// budget returns the refilled budget of key.
+func (l *RateLimiter) budget(key string, now time.Time) *hostBudget {
+	if l.hosts == nil {
+		l.hosts = make(map[string]*hostBudget)
+	}
+	burst := float64(max(l.Burst, 1))
+	b := l.hosts[key]
+	if b == nil {
+		b = &hostBudget{tokens: burst, last: now}
+		l.hosts[key] = b
+	}
+	if l.RequestsPerSecond > 0 {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*l.RequestsPerSecond)
+	}
+	b.last = now
+	return b
+}
+
+// ready reports whether a transfer to key may start now, or else how long
+// to wait before asking again.
+func (l *RateLimiter) ready(key string, now time.Time) (time.Duration, bool) {
+	b := l.budget(key, now)
+	if l.MaxConcurrent > 0 && b.running >= l.MaxConcurrent {
+		return concurrencyPoll, false
+	}
+	if l.RequestsPerSecond > 0 && b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.RequestsPerSecond * float64(time.Second)), false
+	}
+	return 0, true
+}
+
+// acquire blocks until a transfer of curl may start and takes its slot.
+func (l *RateLimiter) acquire(curl *CURL, h *limitedHandle) {
+	l.mu.Lock()
+	for {
+		key := l.key(curl)
+		now := l.clock()
+		wait, ok := l.ready(key, now)
+		if ok {
+			b := l.hosts[key]
+			if l.RequestsPerSecond > 0 {
+				b.tokens--
+			}
+			b.running++
+			h.key, h.held = key, true
+			l.mu.Unlock()
+			return
+		}
+		l.mu.Unlock()
+		curl.logAttrs(_DEBUG, "curl: rate limit delays transfer")
+		time.Sleep(wait)
+		l.mu.Lock()
+	}
+}
+
+func (l *RateLimiter) release(curl *CURL, h *limitedHandle) {
+	var ip string
+	if l.ByIP {
+		ip = curl.getinfoString(INFO_PRIMARY_IP)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.free(h)
+	if ip == "" {
+		return
+	}
+	if l.ips == nil {
+		l.ips = make(map[string]string)
+	}
+	l.ips[urlHost(curl.url)] = ip
+}
+
+// free gives back the slot h holds, if any.
+func (l *RateLimiter) free(h *limitedHandle) {
+	if h.held {
+		if b := l.hosts[h.key]; b != nil {
+			b.running--
+		}
+		h.held = false
+	}
+}
+
+// forget drops curl, releasing a slot it still holds.
+func (l *RateLimiter) forget(curl *CURL, h *limitedHandle) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.free(h)
+	if l.handles[curl] == h {
+		delete(l.handles, curl)
+	}
+}
+
+// urlHost returns the lowercased host name of raw, or raw itself if it has
+// none.
+func urlHost(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return raw
+}
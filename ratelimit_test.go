@@ -0,0 +1,127 @@
+package curl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPerform(t *testing.T) {
+	ts := setupTestServer("")
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+
+	l := &RateLimiter{RequestsPerSecond: 20}
+	if err := l.Attach(easy); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := easy.Perform(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 transfers at 20/s should take at least 100ms and took %v.", elapsed)
+	}
+}
+
+func TestRateLimiterBudget(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &RateLimiter{RequestsPerSecond: 2, Burst: 2, MaxConcurrent: 1, now: func() time.Time { return now }}
+
+	if _, ok := l.ready("a", now); !ok {
+		t.Fatal("fresh host should be ready.")
+	}
+	l.hosts["a"].running = 1
+	if wait, ok := l.ready("a", now); ok || wait != concurrencyPoll {
+		t.Errorf("host at its concurrency cap should wait %v, got %v %v.", concurrencyPoll, wait, ok)
+	}
+	if _, ok := l.ready("b", now); !ok {
+		t.Error("other hosts should not be affected.")
+	}
+
+	l.hosts["a"].running = 0
+	l.hosts["a"].tokens = 0
+	if wait, ok := l.ready("a", now); ok || wait != 500*time.Millisecond {
+		t.Errorf("empty bucket at 2/s should wait 500ms, got %v %v.", wait, ok)
+	}
+	now = now.Add(time.Second)
+	if _, ok := l.ready("a", now); !ok || l.hosts["a"].tokens != 2 {
+		t.Errorf("bucket should refill up to the burst, has %v.", l.hosts["a"].tokens)
+	}
+}
+
+func TestRateLimiterMulti(t *testing.T) {
+	ts := setupTestServer("")
+	defer ts.Close()
+
+	mh := MultiInit()
+	defer mh.Cleanup()
+	l := &RateLimiter{RequestsPerSecond: 50, Burst: 2}
+	l.AttachMulti(mh)
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		easy := EasyInit()
+		defer easy.Cleanup()
+		easy.Setopt(OPT_URL, ts.URL)
+		easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+		if err := mh.AddHandle(easy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completed := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for completed < n && time.Now().Before(deadline) {
+		if _, err := mh.Perform(); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			msg, _ := mh.Info_read()
+			if msg == nil {
+				break
+			}
+			if msg.Err != nil {
+				t.Errorf("transfer failed: %v", msg.Err)
+			}
+			completed++
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if completed != n {
+		t.Errorf("all %d transfers should complete past the burst of 2, %d did.", n, completed)
+	}
+}
+
+func TestRateLimiterRemoveHandle(t *testing.T) {
+	ts := setupTestServer("")
+	defer ts.Close()
+
+	easy := EasyInit()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+
+	mh := MultiInit()
+	defer mh.Cleanup()
+	l := &RateLimiter{MaxConcurrent: 1}
+	l.AttachMulti(mh)
+	if err := mh.AddHandle(easy); err != nil {
+		t.Fatal(err)
+	}
+	if err := mh.RemoveHandle(easy); err != nil {
+		t.Fatal(err)
+	}
+	if b := l.hosts[urlHost(ts.URL)]; b == nil || b.running != 0 {
+		t.Errorf("removing a running transfer should free its slot, got %+v.", b)
+	}
+
+	easy.Cleanup()
+	if len(l.handles) != 0 {
+		t.Errorf("cleaned up handle should be forgotten, %d remain.", len(l.handles))
+	}
+}
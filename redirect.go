@@ -0,0 +1,284 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ErrStopRedirects can be returned by a RedirectFunc to end the chain
+// without error, keeping the redirect response as the final one.
+var ErrStopRedirects = errors.New("curl: stop following redirects")
+
+// RedirectHop is one response of a redirect chain.
+type RedirectHop struct {
+	// URL is the URL that was requested.
+	URL        string
+	Method     string
+	StatusCode int
+	Header     http.Header
+	// Location is the absolute URL the response redirects to, or empty for
+	// the final response.
+	Location string
+}
+
+// Redirect describes the next request of a redirect chain.
+type Redirect struct {
+	// URL is the absolute URL to request next. The RedirectFunc may change
+	// it.
+	URL string
+	// Method is the method the next request uses: 301 and 302 turn a POST
+	// into a GET, 303 turns everything but HEAD into a GET. The
+	// RedirectFunc may change it.
+	Method string
+	// Via are the responses so far, the redirect response last.
+	Via []RedirectHop
+}
+
+// RedirectFunc decides whether to follow a redirect, like
+// http.Client.CheckRedirect. It may change the handle for the next request,
+// e.g. its headers or impersonation target. Returning ErrStopRedirects ends
+// the chain successfully; any other error fails the transfer with it.
+type RedirectFunc func(curl *CURL, next *Redirect) error
+
+type redirectState struct {
+	fn        RedirectFunc
+	chain     []RedirectHop
+	header    http.Header
+	following bool
+	tap       func([]byte)
+	hook      *transferHook
+	// holding is set while a 3xx response arrives; its body is kept in held
+	// until followRedirect knows whether the chain goes on.
+	holding bool
+	held    []byte
+}
+
+// SetRedirectPolicy makes the handle follow redirects in Go instead of
+// libcurl, asking fn before every hop; a nil fn follows up to 10 redirects.
+// It turns OPT_FOLLOWLOCATION off. Every hop is a transfer of its own, so
+// transfer hooks such as a ProxyPool or a Retrier see each of them.
+//
+// As with OPT_FOLLOWLOCATION, the write function only gets the body of the
+// final response; a redirect response's body is passed on only when the
+// chain stops at it.
+//
+// As libcurl does, a hop to another scheme, host or port drops the
+// Authorization, Cookie and Proxy-Authorization headers of OPT_HTTPHEADER
+// and the OPT_USERPWD credentials, unless OPT_UNRESTRICTED_AUTH is set. A
+// body sent again for a 307 or 308 is rewound, which needs a seekable
+// upload body, see UploadBody.
+//
+// The handle keeps the URL, method and credentials of the last hop; set
+// them again before reusing it for the original request.
+func (curl *CURL) SetRedirectPolicy(fn RedirectFunc) error {
+	if fn == nil {
+		fn = defaultRedirectPolicy
+	}
+	if curl.redirect != nil {
+		curl.redirect.fn = fn
+		return nil
+	}
+	if err := curl.Setopt(OPT_FOLLOWLOCATION, false); err != nil {
+		return err
+	}
+	st := &redirectState{fn: fn}
+	st.tap = func(line []byte) {
+		s := strings.TrimRight(string(line), "\r\n")
+		if strings.HasPrefix(s, "HTTP/") {
+			// Every response, including CONNECT and 1xx ones, starts over.
+			st.header = make(http.Header)
+			fields := strings.Fields(s)
+			st.holding = len(fields) > 1 && len(fields[1]) == 3 && fields[1][0] == '3'
+			return
+		}
+		if name, value, ok := strings.Cut(s, ":"); ok && st.header != nil {
+			st.header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	st.hook = &transferHook{
+		start: func(curl *CURL) error {
+			if !st.following {
+				st.chain = nil
+			}
+			st.following = false
+			st.header = nil
+			st.holding, st.held = false, nil
+			return nil
+		},
+		done: func(curl *CURL, err error) error {
+			if err == nil {
+				st.chain = append(st.chain, RedirectHop{
+					URL:        curl.getinfoString(INFO_EFFECTIVE_URL),
+					Method:     curl.requestMethod(),
+					StatusCode: int(curl.getinfoInt(INFO_RESPONSE_CODE)),
+					Header:     st.header,
+					Location:   curl.getinfoString(INFO_REDIRECT_URL),
+				})
+			}
+			return err
+		},
+	}
+	if err := curl.addHeaderTap(&st.tap); err != nil {
+		return err
+	}
+	curl.addTransferHook(st.hook)
+	curl.redirect = st
+	return nil
+}
+
+// RedirectChain returns the responses of the last transfer run with a
+// redirect policy, the final response last.
+func (curl *CURL) RedirectChain() []RedirectHop {
+	if curl.redirect == nil {
+		return nil
+	}
+	return curl.redirect.chain
+}
+
+func defaultRedirectPolicy(curl *CURL, next *Redirect) error {
+	if len(next.Via) >= 10 {
+		return fmt.Errorf("curl: stopped after 10 redirects")
+	}
+	return nil
+}
+
+// followRedirect prepares the handle for the next hop after a successful
+// transfer, reporting false at the end of the chain.
+func (curl *CURL) followRedirect() (bool, error) {
+	follow, err := curl.nextRedirect()
+	st := curl.redirect
+	held := st.held
+	st.holding, st.held = false, nil
+	if follow || err != nil {
+		return follow, err
+	}
+	// The chain ends at this response, so its body is the final one.
+	return false, writeChunks(curl.passWrite, held)
+}
+
+// nextRedirect asks the policy about the redirect of the last transfer and
+// prepares the handle for it.
+func (curl *CURL) nextRedirect() (bool, error) {
+	st := curl.redirect
+	if len(st.chain) == 0 {
+		return false, nil
+	}
+	hop := st.chain[len(st.chain)-1]
+	if hop.Location == "" || hop.StatusCode < 300 || hop.StatusCode > 399 {
+		return false, nil
+	}
+
+	method := hop.Method
+	switch {
+	case hop.StatusCode == 303 && method != "HEAD",
+		(hop.StatusCode == 301 || hop.StatusCode == 302) && method == "POST":
+		method = "GET"
+	}
+	next := &Redirect{URL: hop.Location, Method: method, Via: st.chain}
+	if err := st.fn(curl, next); err != nil {
+		if errors.Is(err, ErrStopRedirects) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := curl.redirectMethod(hop.Method, next.Method); err != nil {
+		return false, err
+	}
+	if !curl.unrestrictedAuth && !sameOrigin(hop.URL, next.URL) {
+		if err := curl.dropCredentials(); err != nil {
+			return false, err
+		}
+	}
+	if err := curl.Setopt(OPT_URL, next.URL); err != nil {
+		return false, err
+	}
+	st.following = true
+	return true, nil
+}
+
+// redirectMethod switches the handle from method to next, and rewinds the
+// upload body when it is sent again.
+func (curl *CURL) redirectMethod(method, next string) error {
+	if next == method {
+		if curl.readFunction == nil || method == "GET" || method == "HEAD" {
+			return nil
+		}
+		return curl.rewindUpload()
+	}
+	switch next {
+	case "GET":
+		if err := curl.Setopt(OPT_HTTPGET, true); err != nil {
+			return err
+		}
+	case "HEAD":
+		if err := curl.Setopt(OPT_NOBODY, true); err != nil {
+			return err
+		}
+	default:
+		return curl.Setopt(OPT_CUSTOMREQUEST, next)
+	}
+	if curl.customRequest != "" {
+		return curl.Setopt(OPT_CUSTOMREQUEST, nil)
+	}
+	return nil
+}
+
+// credentialHeaders are the headers dropped on a redirect to another
+// origin.
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// dropCredentials removes the credentials a redirect must not carry to
+// another origin.
+func (curl *CURL) dropCredentials() error {
+	var kept []string
+	dropped := false
+	for _, line := range curl.httpHeader {
+		name, _, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";")
+		if slices.ContainsFunc(credentialHeaders, func(h string) bool { return strings.EqualFold(h, strings.TrimSpace(name)) }) {
+			dropped = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if dropped {
+		if err := curl.Setopt(OPT_HTTPHEADER, kept); err != nil {
+			return err
+		}
+	}
+	return curl.Setopt(OPT_USERPWD, nil)
+}
+
+// sameOrigin reports whether a and b have the same scheme, host and port.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) &&
+		strings.EqualFold(ua.Hostname(), ub.Hostname()) &&
+		urlPort(ua) == urlPort(ub)
+}
+
+// urlPort returns the port of u, or the default port of its scheme.
+func urlPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func redirectServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Hop", "a")
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "" {
+			http.Redirect(w, r, "/c", http.StatusTemporaryRedirect)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL+"/a")
+	easy.Setopt(OPT_POSTFIELDS, "x=1")
+	var body string
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		body += string(buf)
+		return true
+	})
+
+	var hops int
+	err := easy.SetRedirectPolicy(func(c *CURL, next *Redirect) error {
+		hops++
+		if hops == 1 && next.Method != "GET" {
+			t.Errorf("302 after POST should switch to GET and is %s.", next.Method)
+		}
+		return c.Setopt(OPT_HTTPHEADER, []string{"X-Token: 1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if body != "GET" {
+		t.Errorf("final request should be a GET and was %q.", body)
+	}
+
+	chain := easy.RedirectChain()
+	if len(chain) != 3 {
+		t.Fatalf("chain should have 3 hops and has %d: %+v", len(chain), chain)
+	}
+	if chain[0].StatusCode != 302 || chain[0].Method != "POST" || chain[0].Header.Get("X-Hop") != "a" {
+		t.Errorf("unexpected first hop: %+v", chain[0])
+	}
+	if chain[1].StatusCode != 307 || chain[2].StatusCode != 200 || chain[2].Location != "" {
+		t.Errorf("unexpected hops: %+v", chain[1:])
+	}
+}
+
+func TestRedirectPolicyStop(t *testing.T) {
+	ts := redirectServer()
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL+"/a")
+	var body string
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		body += string(buf)
+		return true
+	})
+
+	easy.SetRedirectPolicy(func(*CURL, *Redirect) error { return ErrStopRedirects })
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := easy.Getinfo(INFO_RESPONSE_CODE); code != int64(302) {
+		t.Errorf("response code should be 302 and is %v.", code)
+	}
+	if !strings.Contains(body, "Found") {
+		t.Errorf("the redirect the chain stops at should be the body, got %q.", body)
+	}
+
+	stop := errors.New("no")
+	easy.SetRedirectPolicy(func(*CURL, *Redirect) error { return stop })
+	easy.Setopt(OPT_URL, ts.URL+"/a")
+	if err := easy.Perform(); !errors.Is(err, stop) {
+		t.Errorf("error should be %v and is %v.", stop, err)
+	}
+}
+
+func TestRedirectPolicyCrossOrigin(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.Header.Get("Authorization"), r.Header.Get("Cookie"), r.Header.Get("X-Keep"))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	for _, unrestricted := range []bool{false, true} {
+		easy := EasyInit()
+		easy.Setopt(OPT_URL, ts.URL+"/")
+		easy.Setopt(OPT_HTTPHEADER, []string{"Authorization: Bearer t", "Cookie: s=1", "X-Keep: 1"})
+		easy.Setopt(OPT_UNRESTRICTED_AUTH, unrestricted)
+		var body string
+		easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+			body += string(buf)
+			return true
+		})
+		easy.SetRedirectPolicy(nil)
+		if err := easy.Perform(); err != nil {
+			t.Fatal(err)
+		}
+		want := "||1"
+		if unrestricted {
+			want = "Bearer t|s=1|1"
+		}
+		if body != want {
+			t.Errorf("unrestricted %v: other origin should see %q and saw %q.", unrestricted, want, body)
+		}
+		easy.Cleanup()
+	}
+}
+
+func TestRedirectPolicyResendBody(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/up", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Method, b)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL+"/up")
+	easy.Setopt(OPT_POST, true)
+	if err := easy.UploadBody(strings.NewReader("payload")); err != nil {
+		t.Fatal(err)
+	}
+	var body string
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		body += string(buf)
+		return true
+	})
+	easy.SetRedirectPolicy(nil)
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if body != "POST payload" {
+		t.Errorf("307 should send the body again and the response is %q.", body)
+	}
+
+	// A RedirectFunc may change the method.
+	body = ""
+	easy.Setopt(OPT_URL, ts.URL+"/up")
+	easy.Setopt(OPT_POST, true)
+	easy.UploadBody(strings.NewReader("payload"))
+	easy.SetRedirectPolicy(func(_ *CURL, next *Redirect) error {
+		next.Method = "GET"
+		return nil
+	})
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if body != "GET " {
+		t.Errorf("redirect changed to GET should send no body and the response is %q.", body)
+	}
+}
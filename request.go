@@ -0,0 +1,251 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Request describes an HTTP request that can be applied to a handle in one
+// call instead of a series of Setopt calls. The setters return the request
+// for chaining.
+type Request struct {
+	Method string
+	URL    string
+	// Query is merged into the query of URL.
+	Query  url.Values
+	Header Header
+
+	body     []byte
+	hasBody  bool
+	reader   io.Reader
+	size     int64
+	bodyType string
+	err      error
+}
+
+// NewRequest returns a request; an empty method means GET.
+func NewRequest(method, rawURL string) *Request {
+	return &Request{Method: method, URL: rawURL}
+}
+
+// SetQuery sets a query parameter, replacing previous values.
+func (r *Request) SetQuery(key, value string) *Request {
+	if r.Query == nil {
+		r.Query = make(url.Values)
+	}
+	r.Query.Set(key, value)
+	return r
+}
+
+// AddQuery adds a query parameter.
+func (r *Request) AddQuery(key, value string) *Request {
+	if r.Query == nil {
+		r.Query = make(url.Values)
+	}
+	r.Query.Add(key, value)
+	return r
+}
+
+// SetHeader sets a header, keeping its position if already present.
+func (r *Request) SetHeader(name, value string) *Request {
+	r.Header.Set(name, value)
+	return r
+}
+
+// AddHeader appends a header.
+func (r *Request) AddHeader(name, value string) *Request {
+	r.Header.Add(name, value)
+	return r
+}
+
+// Body sends b as the request body.
+func (r *Request) Body(b []byte) *Request {
+	r.body, r.hasBody, r.reader, r.bodyType = b, true, nil, ""
+	return r
+}
+
+// JSON sends v encoded as JSON, with Content-Type application/json unless
+// set otherwise.
+func (r *Request) JSON(v any) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.err = fmt.Errorf("curl: failed to encode request body: %w", err)
+		return r
+	}
+	r.Body(b)
+	r.bodyType = "application/json"
+	return r
+}
+
+// Form sends values URL-encoded, with Content-Type
+// application/x-www-form-urlencoded unless set otherwise.
+func (r *Request) Form(values url.Values) *Request {
+	r.Body([]byte(values.Encode()))
+	r.bodyType = "application/x-www-form-urlencoded"
+	return r
+}
+
+// Reader streams the request body from rd, see CURL.UploadBody. size is its
+// length, or -1 to detect it.
+func (r *Request) Reader(rd io.Reader, size int64) *Request {
+	r.body, r.hasBody, r.reader, r.size, r.bodyType = nil, true, rd, size, ""
+	return r
+}
+
+// fullURL returns URL with Query merged in.
+func (r *Request) fullURL() (string, error) {
+	if len(r.Query) == 0 {
+		return r.URL, nil
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return "", fmt.Errorf("curl: invalid URL %q: %w", r.URL, err)
+	}
+	q := u.Query()
+	for k, vs := range r.Query {
+		q[k] = append(q[k], vs...)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Apply sets the URL, method, headers and body of the request on curl.
+// Options the request does not cover stay as they are.
+func (r *Request) Apply(curl *CURL) error {
+	if r.err != nil {
+		return r.err
+	}
+	u, err := r.fullURL()
+	if err != nil {
+		return err
+	}
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	header := append(Header(nil), r.Header...)
+	if r.bodyType != "" && header.Get("Content-Type") == "" {
+		header.Add("Content-Type", r.bodyType)
+	}
+	var lines any
+	if len(header) > 0 {
+		lines = header.Lines()
+	}
+
+	type setting struct {
+		opt   EasyOpt
+		param any
+	}
+	opts := []setting{
+		{OPT_URL, u},
+		{OPT_HTTPHEADER, lines},
+		// HTTPGET resets whatever method and body a previous request set.
+		{OPT_HTTPGET, true},
+		{OPT_CUSTOMREQUEST, nil},
+	}
+	switch {
+	case method == "HEAD":
+		opts = append(opts, setting{OPT_NOBODY, true})
+	case r.reader != nil:
+		// POSTFIELDS of a previous request would take precedence over the
+		// read function.
+		opts = append(opts, setting{OPT_POSTFIELDS, nil}, setting{OPT_POST, true})
+	case r.hasBody && len(r.body) == 0:
+		// An empty []byte would unset POSTFIELDS.
+		opts = append(opts, setting{OPT_POSTFIELDS, ""}, setting{OPT_POSTFIELDSIZE, 0})
+	case r.hasBody:
+		opts = append(opts, setting{OPT_POSTFIELDS, r.body})
+	}
+	// libcurl sends a POST when there is a body, e.g. for a GET with a
+	// JSON query.
+	sent := "GET"
+	switch {
+	case method == "HEAD":
+		sent = "HEAD"
+	case r.reader != nil || r.hasBody:
+		sent = "POST"
+	}
+	if method != sent {
+		opts = append(opts, setting{OPT_CUSTOMREQUEST, method})
+	}
+	for _, o := range opts {
+		if err := curl.Setopt(o.opt, o.param); err != nil {
+			return err
+		}
+	}
+	if r.reader != nil {
+		return curl.uploadBody(r.reader, r.size)
+	}
+	return nil
+}
+
+// Response is a complete HTTP response read into memory by CURL.Do.
+type Response struct {
+	StatusCode int
+	// Header holds the fields of the final response in received order.
+	Header Header
+	Body   []byte
+	// URL is the effective URL after redirects.
+	URL string
+	// Redirects is the redirect chain when a redirect policy is set, see
+	// CURL.SetRedirectPolicy.
+	Redirects []RedirectHop
+}
+
+// JSON decodes the body into v.
+func (r *Response) JSON(v any) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("curl: failed to decode response body: %w", err)
+	}
+	return nil
+}
+
+// Text returns the body as a string.
+func (r *Response) Text() string {
+	return string(r.Body)
+}
+
+// Do applies req to the handle, performs it and returns the response. It
+// replaces the handle's header and write functions.
+func (curl *CURL) Do(req *Request) (*Response, error) {
+	if err := req.Apply(curl); err != nil {
+		return nil, err
+	}
+	var lines []string
+	var body bytes.Buffer
+	err := curl.Setopt(OPT_HEADERFUNCTION, func(buf []byte, _ any) bool {
+		line := string(buf)
+		if strings.HasPrefix(line, "HTTP/") {
+			// Only keep the final response of CONNECT, 1xx and redirects.
+			lines = lines[:0]
+			body.Reset()
+		}
+		lines = append(lines, line)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = curl.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		body.Write(buf)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := curl.Perform(); err != nil {
+		return nil, err
+	}
+	return &Response{
+		StatusCode: int(curl.getinfoInt(INFO_RESPONSE_CODE)),
+		Header:     ParseHeader(lines),
+		Body:       body.Bytes(),
+		URL:        curl.getinfoString(INFO_EFFECTIVE_URL),
+		Redirects:  curl.RedirectChain(),
+	}, nil
+}
@@ -0,0 +1,83 @@
+package curl
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		json.NewEncoder(w).Encode(map[string]string{
+			"method":      r.Method,
+			"query":       r.URL.RawQuery,
+			"contentType": r.Header.Get("Content-Type"),
+			"token":       r.Header.Get("X-Token"),
+			"body":        string(body),
+		})
+	}))
+}
+
+func TestRequestDo(t *testing.T) {
+	ts := echoServer()
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	for _, tc := range []struct {
+		req  *Request
+		want map[string]string
+	}{
+		{
+			NewRequest("POST", ts.URL+"/?a=1").SetQuery("b", "2").SetHeader("X-Token", "t").JSON(map[string]int{"n": 1}),
+			map[string]string{"method": "POST", "query": "a=1&b=2", "contentType": "application/json", "token": "t", "body": `{"n":1}`},
+		},
+		{
+			NewRequest("", ts.URL),
+			map[string]string{"method": "GET", "query": "", "contentType": "", "token": "", "body": ""},
+		},
+		{
+			NewRequest("put", ts.URL).Form(url.Values{"k": {"v"}}),
+			map[string]string{"method": "PUT", "query": "", "contentType": "application/x-www-form-urlencoded", "token": "", "body": "k=v"},
+		},
+		{
+			NewRequest("POST", ts.URL).Reader(strings.NewReader("streamed"), 8),
+			map[string]string{"method": "POST", "query": "", "contentType": "", "token": "", "body": "streamed"},
+		},
+		{
+			NewRequest("GET", ts.URL).JSON(map[string]string{"q": "x"}),
+			map[string]string{"method": "GET", "query": "", "contentType": "application/json", "token": "", "body": `{"q":"x"}`},
+		},
+		{
+			NewRequest("DELETE", ts.URL),
+			map[string]string{"method": "DELETE", "query": "", "contentType": "", "token": "", "body": ""},
+		},
+	} {
+		resp, err := easy.Do(tc.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Errorf("status should be 200 and is %d.", resp.StatusCode)
+		}
+		var got map[string]string
+		if err := resp.JSON(&got); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tc.want {
+			if got[k] != v {
+				t.Errorf("%s %s: %s should be %q and is %q.", tc.req.Method, tc.req.URL, k, v, got[k])
+			}
+		}
+		if m := resp.Header.Get("x-method"); m != tc.want["method"] {
+			t.Errorf("X-Method header should be %q and is %q.", tc.want["method"], m)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrorClass groups transfer outcomes by what went wrong, for deciding
+// whether to try again.
+type ErrorClass int
+
+const (
+	// ClassOK is a successful transfer with a final response.
+	ClassOK ErrorClass = iota
+	// ClassFatal is a failure that another attempt will not fix, such as a
+	// malformed URL, a rejected certificate or a callback abort.
+	ClassFatal
+	ClassDNS
+	// ClassConnect covers refused, reset and dropped connections, and
+	// unreachable proxies.
+	ClassConnect
+	// ClassTLS is a failed handshake. Certificate verification failures
+	// are ClassFatal.
+	ClassTLS
+	ClassTimeout
+	// ClassHTTPStatus is a response with a retryable status, 429 and 5xx by
+	// default.
+	ClassHTTPStatus
+	ClassPartial
+	// ClassHTTP2 covers HTTP/2 and HTTP/3 stream and framing errors.
+	ClassHTTP2
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassOK:
+		return "ok"
+	case ClassFatal:
+		return "fatal"
+	case ClassDNS:
+		return "dns"
+	case ClassConnect:
+		return "connect"
+	case ClassTLS:
+		return "tls"
+	case ClassTimeout:
+		return "timeout"
+	case ClassHTTPStatus:
+		return "http status"
+	case ClassPartial:
+		return "partial"
+	case ClassHTTP2:
+		return "http2"
+	}
+	return fmt.Sprintf("ErrorClass(%d)", int(c))
+}
+
+// Retryable reports whether another attempt may succeed.
+func (c ErrorClass) Retryable() bool {
+	return c != ClassOK && c != ClassFatal
+}
+
+// defaultRetryStatuses are the response codes retried by default.
+var defaultRetryStatuses = []int{429, 500, 502, 503, 504}
+
+// ClassifyError classifies the result err of a transfer. Errors that do not
+// come from libcurl are ClassFatal.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ClassOK
+	}
+	var pe *ProxyError
+	if errors.As(err, &pe) {
+		switch pe.Kind {
+		case ProxyUnavailable, ProxyUpstreamFailed:
+			return ClassConnect
+		}
+		return ClassFatal
+	}
+	var ce CurlError
+	if !errors.As(err, &ce) {
+		return ClassFatal
+	}
+	switch ce {
+	case E_COULDNT_RESOLVE_HOST, E_COULDNT_RESOLVE_PROXY:
+		return ClassDNS
+	case E_COULDNT_CONNECT, E_SEND_ERROR, E_RECV_ERROR, E_GOT_NOTHING, E_QUIC_CONNECT_ERROR:
+		return ClassConnect
+	case E_SSL_CONNECT_ERROR:
+		return ClassTLS
+	case E_OPERATION_TIMEDOUT:
+		return ClassTimeout
+	case E_PARTIAL_FILE:
+		return ClassPartial
+	case E_HTTP2, E_HTTP2_STREAM, E_HTTP3:
+		return ClassHTTP2
+	}
+	return ClassFatal
+}
+
+// Retrier runs transfers again when they fail in a way another attempt may
+// fix, waiting with exponential backoff between attempts.
+type Retrier struct {
+	// MaxAttempts counts all attempts including the first. Zero means 3.
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt; it doubles for every
+	// further one. Zero means 100ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After header asking for longer
+	// ends the retries instead. Zero means 30s.
+	MaxDelay time.Duration
+	// Jitter randomly shortens every wait by up to this fraction, 0 to 1.
+	Jitter float64
+	// RetryStatuses are the response codes retried as ClassHTTPStatus.
+	// Nil means 429, 500, 502, 503 and 504.
+	RetryStatuses []int
+	// Retryable overrides ErrorClass.Retryable, e.g. to not retry POST
+	// requests after the request was sent.
+	Retryable func(curl *CURL, class ErrorClass, err error) bool
+	// OnRetry runs before every further attempt, after the upload body was
+	// rewound, e.g. to reset a response buffer. An error ends the retries
+	// with that error.
+	OnRetry func(curl *CURL, attempt int, err error) error
+
+	mu       sync.Mutex
+	attempts map[*CURL]int
+}
+
+// Classify classifies the outcome of the last transfer of curl, including
+// its response code.
+func (r *Retrier) Classify(curl *CURL, err error) ErrorClass {
+	var ce CurlError
+	if err != nil && !(errors.As(err, &ce) && ce == E_HTTP_RETURNED_ERROR) {
+		return ClassifyError(err)
+	}
+	statuses := r.RetryStatuses
+	if statuses == nil {
+		statuses = defaultRetryStatuses
+	}
+	code := int(curl.getinfoInt(INFO_RESPONSE_CODE))
+	for _, s := range statuses {
+		if code == s {
+			return ClassHTTPStatus
+		}
+	}
+	if err != nil {
+		return ClassFatal
+	}
+	return ClassOK
+}
+
+// Perform runs curl.Perform until it succeeds, fails for good or runs out
+// of attempts, and returns the result of the last attempt.
+func (r *Retrier) Perform(curl *CURL) error {
+	return r.PerformContext(context.Background(), curl)
+}
+
+// PerformContext is Perform with a context that cancels the waits between
+// attempts.
+func (r *Retrier) PerformContext(ctx context.Context, curl *CURL) error {
+	for attempt := 1; ; attempt++ {
+		err := curl.Perform()
+		delay, retry := r.next(curl, attempt, err)
+		if !retry {
+			return err
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if perr := r.prepare(curl, attempt, err); perr != nil {
+			return perr
+		}
+	}
+}
+
+// AttachMulti retries the transfers of m. A completion that is retried is
+// not returned by Info_read; the handle is added again once its wait is
+// over, and Perform counts it as running meanwhile.
+func (r *Retrier) AttachMulti(m *CURLM) {
+	m.removeHooks = append(m.removeHooks, func(easy *CURL) {
+		r.mu.Lock()
+		delete(r.attempts, easy)
+		r.mu.Unlock()
+	})
+	m.doneHooks = append(m.doneHooks, func(msg *CURLMessage) bool {
+		easy := msg.Easy_handle
+		r.mu.Lock()
+		attempt := r.attempts[easy] + 1
+		r.mu.Unlock()
+
+		delay, retry := r.next(easy, attempt, msg.Err)
+		if retry {
+			if err := r.prepare(easy, attempt, msg.Err); err != nil {
+				msg.Err = err
+				retry = false
+			}
+		}
+		if retry {
+			if err := m.requeue(easy, time.Now().Add(delay)); err != nil {
+				easy.logAttrs(_WARN, "curl: failed to requeue transfer for retry", slog.Any("error", err))
+				retry = false
+			}
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if !retry {
+			delete(r.attempts, easy)
+			return false
+		}
+		if r.attempts == nil {
+			r.attempts = make(map[*CURL]int)
+		}
+		r.attempts[easy] = attempt
+		return true
+	})
+}
+
+// next decides whether to retry after attempt ended with err, and how long
+// to wait before.
+func (r *Retrier) next(curl *CURL, attempt int, err error) (time.Duration, bool) {
+	maxAttempts := r.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	if attempt >= maxAttempts {
+		return 0, false
+	}
+	class := r.Classify(curl, err)
+	retry := class.Retryable()
+	if r.Retryable != nil && class != ClassOK {
+		retry = r.Retryable(curl, class, err)
+	}
+	if !retry {
+		return 0, false
+	}
+
+	base, maxDelay := r.BaseDelay, r.MaxDelay
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	delay := maxDelay
+	if shift := attempt - 1; shift < 32 && base<<shift < maxDelay {
+		delay = base << shift
+	}
+	if r.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * min(r.Jitter, 1) * rand.Float64())
+	}
+	if class == ClassHTTPStatus {
+		if after := time.Duration(curl.getinfoInt(INFO_RETRY_AFTER)) * time.Second; after > 0 {
+			if after > maxDelay {
+				return 0, false
+			}
+			delay = max(delay, after)
+		}
+	}
+	curl.logAttrs(_INFO, "curl: retrying transfer",
+		slog.Int("attempt", attempt), slog.String("class", class.String()),
+		slog.Duration("delay", delay), slog.Any("error", err))
+	return delay, true
+}
+
+// prepare gets curl ready for the attempt after attempt.
+func (r *Retrier) prepare(curl *CURL, attempt int, err error) error {
+	if err := curl.rewindUpload(); err != nil {
+		return err
+	}
+	if r.OnRetry != nil {
+		return r.OnRetry(curl, attempt, err)
+	}
+	return nil
+}
+
+// rewindUpload seeks the upload body back to its start, through the seek
+// function if set, or else the READDATA if it can seek. A body read by a
+// READFUNCTION that cannot be rewound is an error, as sending it again
+// would send it truncated.
+func (curl *CURL) rewindUpload() error {
+	if curl.seekFunction != nil {
+		if (*curl.seekFunction)(0, io.SeekStart, curl.seekData) != SEEKFUNC_OK {
+			return fmt.Errorf("curl: failed to rewind upload body")
+		}
+		return nil
+	}
+	s, ok := curl.readData.(io.Seeker)
+	if !ok {
+		if method := curl.requestMethod(); curl.readFunction != nil && method != "GET" && method != "HEAD" {
+			return fmt.Errorf("curl: upload body cannot be rewound to send it again")
+		}
+		return nil
+	}
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("curl: failed to rewind upload body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package curl
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetrierPerform(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	body := strings.NewReader("payload")
+	easy.Setopt(OPT_READDATA, body)
+
+	var retries []int
+	r := &Retrier{
+		BaseDelay: time.Millisecond,
+		OnRetry: func(c *CURL, attempt int, err error) error {
+			retries = append(retries, attempt)
+			if body.Len() != 7 {
+				t.Error("upload body should be rewound before a retry.")
+			}
+			return nil
+		},
+	}
+	body.Seek(0, 2)
+	if err := r.Perform(easy); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("server should see 3 requests and saw %d.", requests)
+	}
+	if len(retries) != 2 || retries[0] != 1 || retries[1] != 2 {
+		t.Errorf("OnRetry should run after attempts [1 2] and ran after %v.", retries)
+	}
+	if code, _ := easy.Getinfo(INFO_RESPONSE_CODE); code != int64(200) {
+		t.Errorf("final response code should be 200 and is %v.", code)
+	}
+}
+
+func TestRetrierUnseekableUpload(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_UPLOAD, true)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	// A MultiReader cannot seek, so the body cannot be sent again.
+	if err := easy.UploadBody(io.MultiReader(strings.NewReader("payload"))); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Retrier{BaseDelay: time.Millisecond}
+	if err := r.Perform(easy); err == nil || !strings.Contains(err.Error(), "rewound") {
+		t.Errorf("retry of an unseekable upload should be refused, got %v.", err)
+	}
+	if requests != 1 {
+		t.Errorf("server should see 1 request and saw %d.", requests)
+	}
+}
+
+func TestRetrierMulti(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+
+	mh := MultiInit()
+	defer mh.Cleanup()
+	(&Retrier{BaseDelay: time.Millisecond}).AttachMulti(mh)
+	if err := mh.AddHandle(easy); err != nil {
+		t.Fatal(err)
+	}
+
+	var done *CURLMessage
+	for done == nil {
+		running, err := mh.Perform()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg, _ := mh.Info_read(); msg != nil {
+			done = msg
+		} else if running == 0 {
+			t.Fatal("transfer should still be running.")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if done.Err != nil || requests != 2 {
+		t.Errorf("transfer should succeed on the second request, got %v after %d requests.", done.Err, requests)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := map[error]ErrorClass{
+		nil:                                   ClassOK,
+		CurlError(E_COULDNT_RESOLVE_HOST):     ClassDNS,
+		CurlError(E_OPERATION_TIMEDOUT):       ClassTimeout,
+		CurlError(E_HTTP2_STREAM):             ClassHTTP2,
+		CurlError(E_PEER_FAILED_VERIFICATION): ClassFatal,
+		&Error{Code: E_PARTIAL_FILE}:          ClassPartial,
+		&ProxyError{Kind: ProxyAuthFailed, Err: CurlError(E_COULDNT_CONNECT)}: ClassFatal,
+	}
+	for err, want := range cases {
+		if got := ClassifyError(err); got != want {
+			t.Errorf("class of %v should be %v and is %v.", err, want, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package curl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// SSLContext wraps the BoringSSL SSL_CTX libcurl passes to
+// OPT_SSL_CTX_FUNCTION. It is only valid for the duration of the callback;
+// libcurl creates a new context for every new TLS connection and calls the
+// function again, while reused connections skip it.
+type SSLContext struct {
+	ptr   unsafe.Pointer
+	curl  *CURL
+	state *sslCtxState
+}
+
+// sslCtxState is what the Go callbacks of an SSL_CTX need. A cgo.Handle of
+// it is kept in the context's ex_data, so the callbacks find it without a
+// global table, and it is released when BoringSSL frees the context.
+type sslCtxState struct {
+	curl   *CURL
+	handle cgo.Handle
+	keyLog func(string)
+	roots  []*x509.CertPool
+	// verifying is set once the verify hook is installed; strict records
+	// whether libcurl asked for peer verification before that. exclusive
+	// makes roots replace BoringSSL's verification, see CertPoolCAs.
+	verifying, strict, exclusive bool
+}
+
+// sslCtxStateFrom returns the state whose handle a BoringSSL callback got.
+func sslCtxStateFrom(handle uintptr) *sslCtxState {
+	v, ok := handleValue(handle)
+	if !ok {
+		return nil
+	}
+	s, _ := v.(*sslCtxState)
+	return s
+}
+
+// getState returns the context's state, attaching it on first use.
+func (ctx *SSLContext) getState() (*sslCtxState, error) {
+	if ctx.state != nil {
+		return ctx.state, nil
+	}
+	s := &sslCtxState{curl: ctx.curl}
+	s.handle = cgo.NewHandle(s)
+	if err := sslCtxSetState(ctx.ptr, s.handle); err != nil {
+		s.handle.Delete()
+		return nil, err
+	}
+	ctx.state = s
+	return s, nil
+}
+
+// Pointer returns the raw SSL_CTX* for callers that link their own BoringSSL
+// bindings.
+func (ctx *SSLContext) Pointer() unsafe.Pointer {
+	return ctx.ptr
+}
+
+// Handle returns the easy handle the connection belongs to.
+func (ctx *SSLContext) Handle() *CURL {
+	return ctx.curl
+}
+
+// AddCertificates adds the certificates of pool as extra trust anchors,
+// next to the CA bundle configured on the handle. An *x509.CertPool cannot
+// be enumerated, so a peer chain BoringSSL fails to verify is verified
+// against pool in Go instead; libcurl still checks the host name.
+func (ctx *SSLContext) AddCertificates(pool *x509.CertPool) error {
+	if pool == nil {
+		return nil
+	}
+	s, err := ctx.getState()
+	if err != nil {
+		return err
+	}
+	if err := ctx.hookVerify(); err != nil {
+		return err
+	}
+	s.roots = append(s.roots, pool)
+	return nil
+}
+
+// AddCertificatesPEM adds every CERTIFICATE block of pemCerts to the
+// context's certificate store as a trust anchor.
+func (ctx *SSLContext) AddCertificatesPEM(pemCerts []byte) error {
+	n := 0
+	for {
+		var block *pem.Block
+		block, pemCerts = pem.Decode(pemCerts)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if err := sslCtxAddCertDER(ctx.ptr, block.Bytes); err != nil {
+			return err
+		}
+		n++
+	}
+	if n == 0 {
+		return fmt.Errorf("curl: no certificates found in PEM data")
+	}
+	return nil
+}
+
+// SetKeyLogFunc makes BoringSSL pass every TLS secret of connections using
+// this context to fn, one NSS key log line at a time without the trailing
+// newline. Passing nil disables key logging.
+func (ctx *SSLContext) SetKeyLogFunc(fn func(line string)) error {
+	s, err := ctx.getState()
+	if err != nil {
+		return err
+	}
+	if err := sslCtxSetKeyLog(ctx.ptr, fn != nil); err != nil {
+		return err
+	}
+	s.keyLog = fn
+	return nil
+}
+
+// hookVerify installs the verify hook on the context, which runs the
+// handle's VerifyPeerFunc and checks the extra trust anchors.
+func (ctx *SSLContext) hookVerify() error {
+	s, err := ctx.getState()
+	if err != nil || s.verifying {
+		return err
+	}
+	strict, err := sslCtxSetVerify(ctx.ptr, s.handle)
+	if err != nil {
+		return err
+	}
+	s.verifying, s.strict = true, strict
+	return nil
+}
+
+// sslCtx sets up key logging and peer verification and runs the handle's
+// OPT_SSL_CTX_FUNCTION for a new SSL_CTX. A CurlError returned by the
+// function is passed to libcurl as is; any other error aborts the
+// connection with E_SSL_CONNECT_ERROR.
+func (curl *CURL) sslCtx(ptr unsafe.Pointer) CurlCode {
+	ctx := &SSLContext{ptr: ptr, curl: curl}
+	curl.setupKeyLog(ctx)
+	if err := curl.setupVerify(ctx); err != nil {
+		curl.logAttrs(_ERROR, "curl: failed to install certificate verification hook", slog.Any("error", err))
+		return E_SSL_CONNECT_ERROR
+	}
+	if curl.sslCtxFunction == nil {
+		return E_OK
+	}
+	err := (*curl.sslCtxFunction)(ctx, curl.sslCtxData)
+	if err == nil {
+		return E_OK
+	}
+	curl.logAttrs(_WARN, "curl: SSL context function failed", slog.Any("error", err))
+	if code, ok := err.(CurlError); ok {
+		return CurlCode(code)
+	}
+	return E_SSL_CONNECT_ERROR
+}
+
+// SetALPS configures the ALPS (application-layer protocol settings) TLS
+// extension Chrome sends: enable turns it on for HTTP/2, newCodepoint
+// selects the codepoint newer Chrome versions use. Impersonate already sets
+// both to match the chosen target; this overrides them.
+func (curl *CURL) SetALPS(enable, newCodepoint bool) error {
+	if err := curl.Setopt(OPT_SSL_ENABLE_ALPS, enable); err != nil {
+		return err
+	}
+	return curl.Setopt(OPT_TLS_USE_NEW_ALPS_CODEPOINT, newCodepoint)
+}
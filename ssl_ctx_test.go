@@ -0,0 +1,59 @@
+package curl
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSSLCtxFunction(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	var keyLog []string
+	easy.Setopt(OPT_URL, ts.URL)
+	easy.Setopt(OPT_WRITEFUNCTION, func([]byte, any) bool { return true })
+	easy.Setopt(OPT_SSL_CTX_DATA, "userdata")
+	err := easy.Setopt(OPT_SSL_CTX_FUNCTION, func(ctx *SSLContext, userdata any) error {
+		if userdata != "userdata" {
+			t.Errorf("userdata should be %q and is %v.", "userdata", userdata)
+		}
+		if err := ctx.AddCertificates(roots); err != nil {
+			return err
+		}
+		return ctx.SetKeyLogFunc(func(line string) { keyLog = append(keyLog, line) })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := easy.Perform(); err != nil {
+		t.Fatalf("transfer trusting the test server certificate failed: %v", err)
+	}
+	if len(keyLog) == 0 {
+		t.Fatal("key log function was not called.")
+	}
+	for _, line := range keyLog {
+		if !strings.HasPrefix(line, "CLIENT_") && !strings.HasPrefix(line, "SERVER_") && !strings.HasPrefix(line, "EXPORTER_") {
+			t.Errorf("unexpected key log line %q", line)
+		}
+	}
+
+	easy.Setopt(OPT_FRESH_CONNECT, true)
+	easy.Setopt(OPT_SSL_CTX_FUNCTION, func(*SSLContext, any) error {
+		return CurlError(E_SSL_CERTPROBLEM)
+	})
+	if err := easy.Perform(); !errors.Is(err, CurlError(E_SSL_CERTPROBLEM)) {
+		t.Errorf("error should be %v and is %v.", CurlError(E_SSL_CERTPROBLEM), err)
+	}
+}
@@ -0,0 +1,233 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// StreamBufferSize is how much of the response body Stream buffers ahead of
+// the reader before it pauses the transfer.
+const StreamBufferSize = 1 << 20
+
+// bodyStream is the reader returned by Stream. The transfer runs on a
+// private multi handle in its own goroutine; when the buffer is full the
+// write function pauses the transfer, and the goroutine resumes it with
+// PAUSE_CONT once the reader has caught up.
+type bodyStream struct {
+	curl  *CURL
+	multi *CURLM
+	// prevWrite is the write function to put back when the transfer ends.
+	prevWrite *func([]byte, any) bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	paused bool
+	closed bool
+	done   bool
+	err    error
+
+	// wake is signalled when a paused transfer can go on.
+	wake     chan struct{}
+	finished chan struct{}
+}
+
+// Stream starts the transfer and returns the response body as a reader,
+// so that large bodies can be processed as they arrive. At most about
+// StreamBufferSize bytes are buffered; a slow reader pauses the transfer
+// instead. The reader returns the transfer error, if any, after the data
+// received before it.
+//
+// Stream sets its own write function for the transfer and puts the
+// previous one back once it ends. The handle must not be used until the
+// reader has returned an error or io.EOF, or has been closed; closing it
+// before the end aborts the transfer.
+func (curl *CURL) Stream() (io.ReadCloser, error) {
+	m := MultiInit()
+	if m == nil {
+		return nil, fmt.Errorf("curl: failed to create multi handle for stream")
+	}
+	s := &bodyStream{
+		curl:      curl,
+		multi:     m,
+		prevWrite: curl.writeFunction,
+		wake:      make(chan struct{}, 1),
+		finished:  make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	if err := curl.Setopt(OPT_WRITEFUNCTION, s.write); err != nil {
+		m.Cleanup()
+		return nil, err
+	}
+	if err := m.AddHandle(curl); err != nil {
+		s.restore()
+		m.Cleanup()
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// restore puts back the handle's write function.
+func (s *bodyStream) restore() {
+	var prev any
+	if s.prevWrite != nil {
+		prev = *s.prevWrite
+	}
+	if err := s.curl.Setopt(OPT_WRITEFUNCTION, prev); err != nil {
+		s.curl.logAttrs(_WARN, "curl: failed to restore write function after stream", slog.Any("error", err))
+	}
+}
+
+// write is the write function of a streamed transfer.
+func (s *bodyStream) write(buf []byte, _ any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		// run aborts the transfer on its next round.
+		return true
+	}
+	if s.buf.Len() >= StreamBufferSize {
+		// libcurl delivers the chunk again after PAUSE_CONT.
+		s.paused = true
+		return false
+	}
+	s.buf.Write(buf)
+	s.cond.Broadcast()
+	return true
+}
+
+// run drives the transfer until it completes or the stream is closed.
+func (s *bodyStream) run() {
+	defer close(s.finished)
+	var err error
+	running := 1
+	for {
+		if s.resume() {
+			if err = s.curl.Pause(PAUSE_CONT); err != nil {
+				break
+			}
+		}
+		running, err = s.multi.Perform()
+		if err != nil || running == 0 || s.isClosed() {
+			break
+		}
+		s.wait()
+	}
+	if err == nil && running == 0 {
+		err = s.result()
+		s.multi.RemoveHandle(s.curl)
+	} else {
+		// No completion message will come, so finish the transfer here.
+		if err == nil {
+			err = CurlError(E_ABORTED_BY_CALLBACK)
+		}
+		err = s.multi.abort(s.curl, err)
+	}
+	s.multi.Cleanup()
+	s.restore()
+
+	s.mu.Lock()
+	s.done = true
+	s.err = err
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// resume reports whether a paused transfer has room to go on.
+func (s *bodyStream) resume() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused && (s.closed || s.buf.Len() < StreamBufferSize) {
+		s.paused = false
+		return true
+	}
+	return false
+}
+
+// wait blocks until the transfer has something to do. A paused transfer
+// has no socket to wait on, so it waits for the reader instead.
+func (s *bodyStream) wait() {
+	timeout, _ := s.multi.Timeout()
+	if timeout < 0 || timeout > 1000 {
+		timeout = 1000
+	}
+	s.mu.Lock()
+	paused := s.paused
+	s.mu.Unlock()
+	if !paused {
+		s.multi.Wait(nil, 0, timeout, nil)
+		return
+	}
+	t := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer t.Stop()
+	select {
+	case <-s.wake:
+	case <-t.C:
+	}
+}
+
+// result returns the error of the completed transfer.
+func (s *bodyStream) result() error {
+	for {
+		msg, _ := s.multi.Info_read()
+		if msg == nil {
+			return nil
+		}
+		if msg.Msg == GetCurlmsgDone() && msg.Easy_handle == s.curl {
+			return msg.Err
+		}
+	}
+}
+
+func (s *bodyStream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *bodyStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *bodyStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.buf.Len() == 0 && !s.done && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return 0, fmt.Errorf("curl: read from closed stream")
+	}
+	if s.buf.Len() == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		return 0, io.EOF
+	}
+	n, _ := s.buf.Read(p)
+	if s.paused && s.buf.Len() < StreamBufferSize {
+		s.signal()
+	}
+	return n, nil
+}
+
+// Close aborts the transfer if it is still running and waits for it to
+// stop.
+func (s *bodyStream) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.buf.Reset()
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.signal()
+	<-s.finished
+	return nil
+}
@@ -0,0 +1,124 @@
+package curl
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789abcdef"), 4*StreamBufferSize/16)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	rc, err := easy.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the transfer run into the buffer limit before reading.
+	time.Sleep(200 * time.Millisecond)
+	s := rc.(*bodyStream)
+	s.mu.Lock()
+	buffered, paused := s.buf.Len(), s.paused
+	s.mu.Unlock()
+	if !paused || buffered > StreamBufferSize+64<<10 {
+		t.Errorf("a stream that is not read should pause at the buffer limit, buffered %d bytes, paused %v.", buffered, paused)
+	}
+
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("streamed body should be %d bytes as sent and is %d bytes.", len(body), len(got))
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := easy.Getinfo(INFO_RESPONSE_CODE); code != int64(200) {
+		t.Errorf("response code should be 200 and is %v.", code)
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 64<<10)
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	easy.Setopt(OPT_URL, ts.URL)
+	rc, err := easy.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(rc, make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rc.Read(make([]byte, 1)); err == nil {
+		t.Error("read after close should fail.")
+	}
+}
+
+func TestStreamRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("final"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	easy := EasyInit()
+	defer easy.Cleanup()
+	var written int
+	easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+		written += len(buf)
+		return true
+	})
+	if err := easy.SetRedirectPolicy(nil); err != nil {
+		t.Fatal(err)
+	}
+	easy.Setopt(OPT_URL, ts.URL+"/a")
+	rc, err := easy.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "final" {
+		t.Errorf("stream should only carry the final body, got %q.", got)
+	}
+
+	// The handle's own write function is back in place.
+	easy.Setopt(OPT_URL, ts.URL+"/b")
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if written != len("final") {
+		t.Errorf("write function should get the body after the stream, got %d bytes.", written)
+	}
+}
@@ -0,0 +1,104 @@
+package curl
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// UploadBody sets r as the body of the request, for uploads (OPT_UPLOAD) as
+// well as POST. It installs a read function and, when r is an io.Seeker, a
+// seek function, so that libcurl can rewind the body when a redirect or an
+// authentication round trip sends it again.
+//
+// The size is taken from a Len method, as bytes.Reader and strings.Reader
+// have, from a regular file or by seeking, and set as OPT_INFILESIZE_LARGE
+// and OPT_POSTFIELDSIZE_LARGE. If it is unknown, HTTP/1.1 uses chunked
+// encoding.
+func (curl *CURL) UploadBody(r io.Reader) error {
+	return curl.uploadBody(r, -1)
+}
+
+// uploadBody is UploadBody with a known size, or -1 to detect it.
+func (curl *CURL) uploadBody(r io.Reader, size int64) error {
+	if size < 0 {
+		size = bodySize(r)
+	}
+	err := curl.Setopt(OPT_READFUNCTION, func(buf []byte, _ any) int {
+		for {
+			n, err := r.Read(buf)
+			if err != nil && err != io.EOF {
+				curl.logAttrs(_ERROR, "curl: failed to read upload body", slog.Any("error", err))
+				return -1
+			}
+			// Zero means the end of the body to libcurl.
+			if n > 0 || err == io.EOF {
+				return n
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := curl.Setopt(OPT_READDATA, r); err != nil {
+		return err
+	}
+
+	// A pipe is an io.Seeker too, but fails to seek.
+	var seek any
+	if s, ok := r.(io.Seeker); ok {
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			// libcurl only seeks from the start of the body.
+			seek = func(offset int64, origin int, _ any) int {
+				if origin != io.SeekStart {
+					return SEEKFUNC_CANTSEEK
+				}
+				if _, err := s.Seek(start+offset, io.SeekStart); err != nil {
+					return SEEKFUNC_FAIL
+				}
+				return SEEKFUNC_OK
+			}
+		}
+	}
+	if err := curl.Setopt(OPT_SEEKFUNCTION, seek); err != nil {
+		return err
+	}
+
+	// Both are set, also to -1, so that no size of an earlier body is left.
+	if err := curl.Setopt(OPT_INFILESIZE_LARGE, size); err != nil {
+		return err
+	}
+	return curl.Setopt(OPT_POSTFIELDSIZE_LARGE, size)
+}
+
+// bodySize returns the number of bytes left in r, or -1 if unknown.
+func bodySize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return -1
+		}
+		off, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return fi.Size() - off
+	case io.Seeker:
+		cur, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		end, err := v.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1
+		}
+		if _, err := v.Seek(cur, io.SeekStart); err != nil {
+			return -1
+		}
+		return end - cur
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package curl
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadBody(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Chunked", strings.Join(r.TransferEncoding, ","))
+		w.Write(body)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name    string
+		body    io.Reader
+		path    string
+		chunked string
+	}{
+		// A 307 sends the body again, which needs the seek function.
+		{"seeker", strings.NewReader("payload"), "/redirect", ""},
+		{"plain reader", io.MultiReader(strings.NewReader("pay"), strings.NewReader("load")), "/echo", "chunked"},
+	} {
+		easy := EasyInit()
+		var got string
+		var chunked string
+		easy.Setopt(OPT_URL, ts.URL+tc.path)
+		easy.Setopt(OPT_POST, true)
+		easy.Setopt(OPT_FOLLOWLOCATION, true)
+		easy.Setopt(OPT_HEADERFUNCTION, func(buf []byte, _ any) bool {
+			if name, value, ok := strings.Cut(string(buf), ":"); ok && strings.EqualFold(name, "X-Chunked") {
+				chunked = strings.TrimSpace(value)
+			}
+			return true
+		})
+		easy.Setopt(OPT_WRITEFUNCTION, func(buf []byte, _ any) bool {
+			got += string(buf)
+			return true
+		})
+		if err := easy.UploadBody(tc.body); err != nil {
+			t.Fatal(err)
+		}
+		if err := easy.Perform(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != "payload" {
+			t.Errorf("%s: echoed body should be %q and is %q.", tc.name, "payload", got)
+		}
+		if chunked != tc.chunked {
+			t.Errorf("%s: transfer encoding should be %q and is %q.", tc.name, tc.chunked, chunked)
+		}
+		easy.Cleanup()
+	}
+}
+
+func TestBodySize(t *testing.T) {
+	r := strings.NewReader("0123456789")
+	r.Seek(4, io.SeekStart)
+	if n := bodySize(r); n != 6 {
+		t.Errorf("size of a reader at offset 4 should be 6 and is %d.", n)
+	}
+	if n := bodySize(io.LimitReader(r, 2)); n != -1 {
+		t.Errorf("size of a plain reader should be unknown and is %d.", n)
+	}
+}
@@ -0,0 +1,193 @@
+package curl
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PublicKeyPin returns the OPT_PINNEDPUBLICKEY pin ("sha256//<base64>") of
+// key, which must be a type x509.MarshalPKIXPublicKey supports.
+func PublicKeyPin(key crypto.PublicKey) (string, error) {
+	spki, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("curl: failed to encode public key for pinning: %w", err)
+	}
+	sum := sha256.Sum256(spki)
+	return SPKIHashPin(sum[:]), nil
+}
+
+// SPKIHashPin returns the pin for sum, the SHA-256 hash of a DER encoded
+// SubjectPublicKeyInfo as published by e.g. HPKP tooling.
+func SPKIHashPin(sum []byte) string {
+	return "sha256//" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// SetPinnedPublicKeys makes connections to servers fail with
+// E_SSL_PINNEDPUBKEYNOTMATCH unless the server key matches one of pins, see
+// PublicKeyPin. Calling it without pins removes pinning.
+func (curl *CURL) SetPinnedPublicKeys(pins ...string) error {
+	return curl.setPins(OPT_PINNEDPUBLICKEY, pins)
+}
+
+// SetProxyPinnedPublicKeys is SetPinnedPublicKeys for HTTPS proxies.
+func (curl *CURL) SetProxyPinnedPublicKeys(pins ...string) error {
+	return curl.setPins(OPT_PROXY_PINNEDPUBLICKEY, pins)
+}
+
+func (curl *CURL) setPins(opt EasyOpt, pins []string) error {
+	if len(pins) == 0 {
+		return curl.Setopt(opt, nil)
+	}
+	return curl.Setopt(opt, strings.Join(pins, ";"))
+}
+
+// VerifyPeerFunc inspects the certificate chain of a server or HTTPS proxy
+// during the handshake. chain starts with the leaf followed by the
+// certificates the peer sent. verifyErr is the result of BoringSSL's own
+// verification against the configured CAs; with OPT_SSL_VERIFYPEER enabled
+// the connection fails on it regardless of the function's answer. Returning
+// an error aborts the connection.
+type VerifyPeerFunc func(chain []*x509.Certificate, verifyErr error) error
+
+// CertificateVerificationError is returned by Perform and in CURLMessage.Err
+// when a VerifyPeerFunc rejected the peer. errors.Is matches it against
+// both the function's error and CurlError(E_PEER_FAILED_VERIFICATION).
+type CertificateVerificationError struct {
+	Chain []*x509.Certificate
+	Err   error
+}
+
+func (e *CertificateVerificationError) Error() string {
+	return "curl: peer certificate rejected: " + e.Err.Error()
+}
+
+func (e *CertificateVerificationError) Unwrap() []error {
+	return []error{e.Err, CurlError(E_PEER_FAILED_VERIFICATION)}
+}
+
+// SetVerifyPeerFunction installs fn to check the peer of every new TLS
+// connection, in addition to libcurl's own verification. Passing nil
+// removes it. It is not available on Windows and returns an error there.
+func (curl *CURL) SetVerifyPeerFunction(fn VerifyPeerFunc) error {
+	if fn != nil && runtime.GOOS == "windows" {
+		// The hook needs the BoringSSL context, see sslCtxSetVerify.
+		return fmt.Errorf("curl: certificate verification hooks are not available on windows")
+	}
+	curl.verifyFunction = fn
+	if fn == nil {
+		return nil
+	}
+	if curl.verifyHook == nil {
+		curl.verifyHook = &transferHook{
+			start: func(curl *CURL) error {
+				curl.verifyErr = nil
+				return nil
+			},
+			done: func(curl *CURL, err error) error {
+				if curl.verifyErr != nil && err != nil {
+					err = curl.verifyErr
+				}
+				curl.verifyErr = nil
+				return err
+			},
+		}
+		curl.addTransferHook(curl.verifyHook)
+	}
+	return curl.installSSLCtxCallback()
+}
+
+// setupVerify installs the verify hook on a new SSL context.
+func (curl *CURL) setupVerify(ctx *SSLContext) error {
+	if curl.verifyFunction == nil && curl.caPool == nil {
+		return nil
+	}
+	if err := ctx.hookVerify(); err != nil {
+		return err
+	}
+	if curl.caPool != nil {
+		// No bundle is loaded for a CertPoolCAs source, so BoringSSL's
+		// verdict is replaced by the pool's.
+		ctx.state.roots = append(ctx.state.roots, curl.caPool)
+		ctx.state.exclusive = true
+	}
+	return nil
+}
+
+// Verdicts of verifyPeer; the C hook clears BoringSSL's verification error
+// for verifyTrusted.
+const (
+	verifyReject = iota
+	verifyAccept
+	verifyTrusted
+)
+
+// verifyPeer decides whether the handshake may continue with the peer
+// chain ders.
+func (s *sslCtxState) verifyPeer(ders [][]byte, verifyErr error) int {
+	curl := s.curl
+	if curl.verifyFunction == nil && !s.exclusive && (verifyErr == nil || len(s.roots) == 0) {
+		if verifyErr == nil || !s.strict {
+			return verifyAccept
+		}
+		return verifyReject
+	}
+	chain := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			if curl.verifyFunction == nil {
+				if s.strict {
+					return verifyReject
+				}
+				return verifyAccept
+			}
+			curl.verifyErr = &CertificateVerificationError{Err: fmt.Errorf("curl: failed to parse peer certificate: %w", err)}
+			return verifyReject
+		}
+		chain = append(chain, cert)
+	}
+	trusted := false
+	if verifyErr != nil || s.exclusive {
+		rootsErr := verifyRoots(chain, s.roots)
+		if trusted = rootsErr == nil; trusted || s.exclusive {
+			verifyErr = rootsErr
+		}
+	}
+	if curl.verifyFunction != nil {
+		if err := curl.verifyFunction(chain, verifyErr); err != nil {
+			curl.verifyErr = &CertificateVerificationError{Chain: chain, Err: err}
+			return verifyReject
+		}
+	}
+	switch {
+	case trusted:
+		return verifyTrusted
+	case verifyErr == nil || !s.strict:
+		return verifyAccept
+	}
+	return verifyReject
+}
+
+// verifyRoots verifies chain, leaf first, against each of roots and returns
+// nil as soon as one of them succeeds. The host name is left to libcurl.
+func verifyRoots(chain []*x509.Certificate, roots []*x509.CertPool) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("curl: peer sent no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	err := fmt.Errorf("curl: no trust anchors to verify the peer against")
+	for _, pool := range roots {
+		if _, err = chain[0].Verify(x509.VerifyOptions{Roots: pool, Intermediates: intermediates}); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package curl
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyPeerFunction(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	easy, err := NewEasy(
+		WithCASource(CertificateCAs(ts.Certificate())),
+		WithOption(OPT_URL, ts.URL),
+		WithOption(OPT_FRESH_CONNECT, true),
+		WithOption(OPT_WRITEFUNCTION, func([]byte, any) bool { return true }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer easy.Cleanup()
+
+	var leaf *x509.Certificate
+	easy.SetVerifyPeerFunction(func(chain []*x509.Certificate, verifyErr error) error {
+		if verifyErr != nil {
+			t.Errorf("default verification should pass and failed with %v.", verifyErr)
+		}
+		leaf = chain[0]
+		return nil
+	})
+	if err := easy.Perform(); err != nil {
+		t.Fatal(err)
+	}
+	if leaf == nil || !leaf.Equal(ts.Certificate()) {
+		t.Error("verify function should receive the server certificate.")
+	}
+
+	reject := errors.New("not our server")
+	easy.SetVerifyPeerFunction(func([]*x509.Certificate, error) error { return reject })
+	err = easy.Perform()
+	var verr *CertificateVerificationError
+	if !errors.As(err, &verr) || !errors.Is(err, reject) || !errors.Is(err, CurlError(E_PEER_FAILED_VERIFICATION)) {
+		t.Errorf("unexpected error for rejected peer: %v", err)
+	}
+
+	easy.SetVerifyPeerFunction(nil)
+	pin, err := PublicKeyPin(ts.Certificate().PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	easy.SetPinnedPublicKeys(pin)
+	if err := easy.Perform(); err != nil {
+		t.Errorf("transfer with matching pin failed: %v", err)
+	}
+	easy.SetPinnedPublicKeys(SPKIHashPin(make([]byte, 32)))
+	if err := easy.Perform(); !errors.Is(err, CurlError(E_SSL_PINNEDPUBKEYNOTMATCH)) {
+		t.Errorf("error should be %v and is %v.", CurlError(E_SSL_PINNEDPUBKEYNOTMATCH), err)
+	}
+}